@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/reconcile"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+)
+
+// runCacheMigrate handles `cache-migrate`: it rewrites orders cached in hash layout
+// after switching to the blob one, returns exit code
+func runCacheMigrate(log *slog.Logger, cache_conf config.CacheConfig, redis_conf config.RedisConfig) int {
+	if cache_conf.Mode == "memory" {
+		log.Error("Cache migration needs redis, cache mode is memory")
+		return 1
+	}
+	// unreachable redis fails the migration below
+	rdb, _ := redisclient.InitRedis(redis_conf, log)
+	defer rdb.Conn.Close()
+
+	migrated, err := rdb.MigrateLayout(context.Background(), log)
+	if err != nil {
+		log.Error(fmt.Sprintf("Cache migration failed: %v", err), slog.Int("orders", migrated))
+		return 1
+	}
+	log.Info("Cache migration finished", slog.Int("orders", migrated))
+	return 0
+}
+
+// runReconcile handles `reconcile [repair]`: it reports cached orders disagreeing
+// with db and fixes them with repair, returns exit code
+func runReconcile(log *slog.Logger, cfg *config.Config, args []string) int {
+	if cfg.Cache.Mode == "memory" {
+		log.Error("Cache reconciliation needs redis, cache mode is memory")
+		return 1
+	}
+	rdb, _ := redisclient.InitRedis(cfg.Redis, log)
+	defer rdb.Conn.Close()
+	db, err := storage.Open(log, cfg.App, cfg.Storage, cfg.Postgres)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to create storage instance: %v", err))
+		return 1
+	}
+	defer db.Close()
+
+	repair := len(args) > 0 && args[0] == "repair"
+	report, err := reconcile.New(log, rdb, db, cfg.Redis).Run(context.Background(), repair)
+	if err != nil {
+		log.Error(fmt.Sprintf("Cache reconciliation failed: %v", err))
+		return 1
+	}
+	json.NewEncoder(os.Stdout).Encode(report)
+	return 0
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
@@ -13,17 +12,32 @@ import (
 	"time"
 
 	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/consumer"
+	"github.com/EgorcaA/create_db/internal/dlq"
+	"github.com/EgorcaA/create_db/internal/feed"
 	"github.com/EgorcaA/create_db/internal/generator"
 	"github.com/EgorcaA/create_db/internal/handler"
+	"github.com/EgorcaA/create_db/internal/health"
 	"github.com/EgorcaA/create_db/internal/logger/sl"
-	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/memcache"
+	"github.com/EgorcaA/create_db/internal/reconcile"
 	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/retry"
 	"github.com/EgorcaA/create_db/internal/server"
 	"github.com/EgorcaA/create_db/internal/storage"
-	"github.com/IBM/sarama"
+	"github.com/EgorcaA/create_db/internal/tracing"
 )
 
+// time given to all readiness checks together
+const readinessTimeout = 2 * time.Second
+
 func main() {
+	os.Exit(run())
+}
+
+// run starts the service or runs a subcommand and returns the exit code,
+// deferred cleanups like trace flushing are done before the process exits
+func run() int {
 
 	//setting up env
 	dir, err := os.Getwd()
@@ -41,103 +55,149 @@ func main() {
 	log.Debug("debug messages are enabled")
 	log.Debug(fmt.Sprintf("Current directory: %s", dir))
 
-	//redis init
-	rdb, _ := redisclient.InitRedis(cfg.Redis, log)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.App, cfg.Tracing)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to set up tracing: %v", err))
+		return 1
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error(fmt.Sprintf("Error flushing traces: %v", err))
+		}
+	}()
+
+	// migrate subcommand needs only the db
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(log, cfg.App, cfg.Storage, cfg.Postgres, os.Args[2:])
+	}
+	// cache-migrate needs only redis
+	if len(os.Args) > 1 && os.Args[1] == "cache-migrate" {
+		return runCacheMigrate(log, cfg.Cache, cfg.Redis)
+	}
+	// reconcile needs redis and the db
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		return runReconcile(log, cfg, os.Args[2:])
+	}
+
+	//cache init, in-process only cache doesn't need redis
+	var (
+		rdb   *redisclient.RedisCache
+		cache redisclient.CacheClient
+		l1    *memcache.Cache
+	)
+	if cfg.Cache.Mode != "memory" {
+		rdb, _ = redisclient.InitRedis(cfg.Redis, log)
+	}
+	switch cfg.Cache.Mode {
+	case "memory":
+		l1 = memcache.New(cfg.Cache, nil)
+		cache = l1
+	case "tiered":
+		l1 = memcache.New(cfg.Cache, rdb)
+		cache = l1
+	default:
+		cache = rdb
+	}
+	log.Info("Cache is set up", slog.String("mode", cfg.Cache.Mode))
 
 	//db init
-	db, err := storage.New(log, cfg.Postgres)
+	db, err := storage.Open(log, cfg.App, cfg.Storage, cfg.Postgres)
 	if err != nil {
 		log.Info(fmt.Sprintf("Failed to create storage instance: %v", err))
-		os.Exit(1)
+		return 1
 	}
-	defer db.Conn.Close()
+	defer db.Close()
 
 	//kafka
-	brokers := []string{cfg.Kafka.BootstrapServers} // Kafka brockers
-	topic := cfg.Kafka.Topic                        // def "orders"
-
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-
-	client, err := sarama.NewClient(brokers, config)
+	group, err := consumer.NewConsumerGroup(cfg.Kafka)
 	if err != nil {
-		log.Error(fmt.Sprintf("Failed to connect to Kafka: %v", err))
+		log.Error(fmt.Sprintf("Failed creating Kafka consumer group: %v", err))
+		return 1
 	}
-	defer client.Close()
+	defer group.Close()
+	log.Info("Succeded creating Kafka consumer group", slog.String("group_id", cfg.Kafka.GroupID))
 
-	consumer, err := sarama.NewConsumerFromClient(client)
+	dlqPublisher, err := dlq.NewPublisher(cfg.Kafka, cfg.Kafka.DeadLetterTopic)
 	if err != nil {
-		log.Error(fmt.Sprintf("Failed creating Kafka consumer: %v", err))
+		log.Error(fmt.Sprintf("Failed creating dead-letter publisher: %v", err))
+		return 1
 	}
-	defer consumer.Close()
-
-	partitionConsumer, err := consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
+	defer dlqPublisher.Close()
+	quarantinePublisher, err := dlq.NewPublisher(cfg.Kafka, cfg.Kafka.QuarantineTopic)
 	if err != nil {
-		log.Error(fmt.Sprintf("Failed subribing the topic: %v", err))
-	} else {
-		log.Info("Succeded subribing Kafka topic")
+		log.Error(fmt.Sprintf("Failed creating quarantine publisher: %v", err))
+		return 1
 	}
-	defer partitionConsumer.Close()
+	defer quarantinePublisher.Close()
+	retries := handler.Retries{
+		DB:    retry.NewPolicy(cfg.Storage.Retry),
+		Cache: retry.NewPolicy(cfg.Redis.Retry),
+	}
+	broadcaster := feed.NewBroadcaster()
+	orderConsumer := consumer.NewOrderConsumer(log, cache, db, dlqPublisher, retries,
+		cfg.Validation, quarantinePublisher, broadcaster)
 	// kafka end
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Restore cache from database
-	rdb.RestoreCacheFromDB(ctx, log, db)
-
 	// System signals
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	//in case of debug use test_channel
-	// test_channel := make(chan order_struct.Order, 10)
-	// go generator.Spam_channel(test_channel)
-	//smaple script to spam into kafka channel
-	go generator.Spam_kafka(log, cfg.Kafka)
-
-	go func() {
-		for {
-			select {
-			// case msg := <-test_channel:
-			case msg, ok := <-partitionConsumer.Messages():
-				if !ok {
-					log.Warn("Messages channel closed, exiting goroutine")
-					return
-				}
-
-				if msg.Value == nil {
-					log.Warn("Message value is nil, skipping")
-					continue
-				}
-				var order order_struct.Order
-				if err := json.Unmarshal(msg.Value, &order); err != nil {
-					log.Warn(fmt.Sprintf("Error unmarshal the message: %v", err))
-					continue
-				}
-				handler.Handle_message(log, ctx, rdb, order, db)
-
-			case <-signals:
-				log.Info("Received termination signal...")
+	// subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "redrive":
+			// re-handle dead-lettered messages once the cause is fixed
+			go func() {
+				<-signals
 				cancel()
-				return
-			case err := <-partitionConsumer.Errors():
-				log.Warn(fmt.Sprintf("Kafka error: %v", err))
+			}()
+			if err := consumer.Redrive(ctx, log, cfg.Kafka, orderConsumer); err != nil {
+				log.Error(fmt.Sprintf("Redrive failed: %v", err))
+				return 1
 			}
+		default:
+			log.Error(fmt.Sprintf("Unknown command: %s", os.Args[1]))
+			return 1
 		}
-	}()
+		return 0
+	}
+
+	// readiness of every dependency
+	cacheRestored := health.NewFlag("cache restore is in progress")
+	checker := health.NewChecker(readinessTimeout)
+	checker.Add(cfg.App.Storage, func(ctx context.Context) (any, error) { return nil, db.Ping(ctx) })
+	if rdb != nil {
+		checker.Add("redis", func(ctx context.Context) (any, error) { return nil, rdb.Ping(ctx) })
+	}
+	if l1 != nil {
+		// never fails, reports hit rate
+		checker.Add("memory_cache", func(ctx context.Context) (any, error) { return l1.Stats(), nil })
+	}
+	checker.Add("kafka", orderConsumer.ReadyCheck(cfg.Kafka.MaxLag))
+	checker.Add("cache_restore", cacheRestored.Check)
+	restorer := server.NewRestorer(log, cache, db)
 
 	http.HandleFunc("/", server.IndexHandler)
-	http.HandleFunc("/user", server.OrderHandler(ctx, rdb))
+	http.HandleFunc("/user", server.OrderHandler(cache, db))
+	server.RegisterAPI(http.DefaultServeMux, cache, db)
+	server.RegisterUI(http.DefaultServeMux, cache, db)
+	server.RegisterFeed(http.DefaultServeMux, ctx, broadcaster)
+	server.RegisterHealth(http.DefaultServeMux, checker)
+	server.RegisterMetrics(http.DefaultServeMux)
+	server.RegisterRestore(http.DefaultServeMux, ctx, restorer)
 
 	srv := &http.Server{
-		Addr: ":8080",
+		Addr:    ":8080",
+		Handler: server.Instrument(http.DefaultServeMux),
 		// ReadTimeout:  cfg.HTTPServer.Timeout,
 		// WriteTimeout: cfg.HTTPServer.Timeout,
 		// IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	// server run
+	// server run, it is up before the cache restore so probes see its progress
 	go func() {
 		log.Info("Server is up at http://localhost:8080")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -145,9 +205,39 @@ func main() {
 		}
 	}()
 
+	// local copies of orders changed by other instances are dropped
+	if cfg.Cache.Mode == "tiered" {
+		go rdb.SubscribeInvalidations(ctx, log, l1.Evict, l1.Flush)
+	}
+
+	// cache is checked against db after outages the restore doesn't cover
+	if rdb != nil && cfg.Reconcile.Interval > 0 {
+		go reconcile.New(log, rdb, db, cfg.Redis).RunEvery(ctx, cfg.Reconcile.Interval)
+	}
+
+	// Restore cache from database, it may be run again later over the API
+	if _, err := restorer.Run(ctx); err != nil {
+		log.Error(fmt.Sprintf("Error restoring cache: %v", err))
+	}
+	cacheRestored.Set()
+
+	//in case of debug use test_channel
+	// test_channel := make(chan order_struct.Order, 10)
+	// go generator.Spam_channel(test_channel)
+	//smaple script to spam into kafka channel
+	go generator.Spam_kafka(log, cfg.Kafka)
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Run(ctx, log, group, []string{cfg.Kafka.Topic}, orderConsumer)
+	}()
+
 	// Waiting termination signal
 	<-signals
 	log.Info("Received termination signal, finalizing...")
+	cancel()
+	<-consumerDone
 
 	// Ending HTTP-server
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
@@ -156,6 +246,9 @@ func main() {
 	if err := srv.Shutdown(ctxShutdown); err != nil {
 		log.Error(fmt.Sprintf("Error closing server: %v", err))
 	}
-	rdb.Conn.Close()
+	if rdb != nil {
+		rdb.Conn.Close()
+	}
 	log.Info("Server is closed")
+	return 0
 }
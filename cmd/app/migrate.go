@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/storage"
+)
+
+// runMigrate handles `migrate up|down [N]|version|force V`, returns exit code
+func runMigrate(log *slog.Logger, app_conf config.AppConfig, storage_conf config.StorageConfig,
+	Postgres_conf config.PostgresConfig, args []string) int {
+	storage_conf.AutoMigrate = false
+	db, err := storage.Open(log, app_conf, storage_conf, Postgres_conf)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to create storage instance: %v", err))
+		return 1
+	}
+	defer db.Close()
+
+	mg, err := db.NewMigrator(context.Background())
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+	defer mg.Close()
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		err = mg.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Error(fmt.Sprintf("Invalid number of steps: %s", args[1]))
+				return 1
+			}
+		}
+		err = mg.Down(steps)
+	case "force":
+		if len(args) < 2 {
+			log.Error("Usage: migrate force VERSION")
+			return 1
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			log.Error(fmt.Sprintf("Invalid version: %s", args[1]))
+			return 1
+		}
+		err = mg.Force(version)
+	case "version":
+	default:
+		log.Error(fmt.Sprintf("Unknown migrate action: %s (up, down [N], version, force VERSION)", action))
+		return 1
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("Migration %s failed: %v", action, err))
+		return 1
+	}
+
+	version, dirty, err := mg.Version()
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to check schema version: %v", err))
+		return 1
+	}
+	log.Info("Schema version", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+	return 0
+}
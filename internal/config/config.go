@@ -3,25 +3,45 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type AppConfig struct {
-	Name        string `yaml:"name" env-default:"L0"`
-	Version     string `yaml:"version" env-default:"1.0.0"`
+	Name    string `yaml:"name" env-default:"L0"`
+	Version string `yaml:"version" env-default:"1.0.0"`
+	// "postgres" or "sqlite", the embedded one keeps orders in StoragePath
+	Storage     string `yaml:"storage" env-default:"postgres"`
 	StoragePath string `yaml:"storage_path" env-default:"./storage.db"`
 	Env         string `yaml:"env" env-default:"local"`
 }
 
-// PostgresConfig represents the PostgreSQL configuration
+// PostgresConfig represents the PostgreSQL configuration,
+// user and password are required only when it is the storage backend
 type PostgresConfig struct {
 	Host     string `yaml:"host" env-default:"localhost"`
 	Port     int    `yaml:"port" env-default:"5433"`
-	User     string `yaml:"user" env-required:"true"`
-	Password string `yaml:"password" env-required:"true"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
 	Name     string `yaml:"name" env-default:"l0"`
 	PGDriver string `yaml:"pg_driver" env-default:"pq"`
+	// connection pool, zero keeps database/sql defaults
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"20"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env-default:"5m"`
+}
+
+// StorageConfig represents the settings shared by every storage backend
+type StorageConfig struct {
+	// "update" or "keep" stored order when a redelivered one differs
+	OnConflict string `yaml:"on_conflict" env-default:"update"`
+	// apply pending schema migrations on startup
+	AutoMigrate bool `yaml:"auto_migrate" env-default:"true"`
+	// deadline of a single query or transaction, 0 means none
+	QueryTimeout time.Duration `yaml:"query_timeout" env-default:"5s"`
+	Retry        RetryConfig   `yaml:"retry"`
 }
 
 // HTTPConfig represents the HTTP server configuration
@@ -34,21 +54,87 @@ type HTTPConfig struct {
 type KafkaConfig struct {
 	BootstrapServers string `yaml:"bootstrap_servers" env-default:"localhost:9092"`
 	Topic            string `yaml:"topic" env-default:"orders"`
+	GroupID          string `yaml:"group_id" env-default:"orders-service"`
+	DeadLetterTopic  string `yaml:"dead_letter_topic" env-default:"orders.dlq"`
+	QuarantineTopic  string `yaml:"quarantine_topic" env-default:"orders.quarantine"`
+	// "range", "roundrobin" or "sticky"
+	RebalanceStrategy string `yaml:"rebalance_strategy" env-default:"range"`
+	// service is not ready while the total lag of its partitions is higher
+	MaxLag int64 `yaml:"max_lag" env-default:"1000"`
 }
 
 // Redis config
 type RedisConfig struct {
-	Host string `yaml:"host" env-default:"localhost"`
-	Port string `yaml:"port" env-default:"6379"`
+	Host  string      `yaml:"host" env-default:"localhost"`
+	Port  string      `yaml:"port" env-default:"6379"`
+	Retry RetryConfig `yaml:"retry"`
+	// cached order expires after it was last written, 0 keeps it forever
+	OrderTTL time.Duration `yaml:"order_ttl" env-default:"168h"`
+	// startup restore loads only orders created within the window, 0 loads all of them
+	RestoreWindow time.Duration `yaml:"restore_window" env-default:"168h"`
+	// and no more than this many of the newest ones, 0 means no limit
+	RestoreLimit int `yaml:"restore_limit" env-default:"10000"`
+	// "hash" keeps every part of an order in its own key, "blob" keeps the whole order in one value
+	Layout string `yaml:"layout" env-default:"hash"`
+	// blob encoding, "json" or "msgpack"
+	Encoding string `yaml:"encoding" env-default:"json"`
+	// gzip blobs
+	Compress bool `yaml:"compress" env-default:"false"`
+}
+
+// RetryConfig represents retry policy for transient failures of a dependency
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"100ms"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"5s"`
+	Jitter      float64       `yaml:"jitter" env-default:"0.2"`
+}
+
+// CacheConfig represents which cache tiers serve orders
+type CacheConfig struct {
+	// "redis", "memory" (in-process only, no redis) or "tiered" (in-process in front of redis)
+	Mode string `yaml:"mode" env-default:"redis"`
+	// in-process cache bounds
+	Size int           `yaml:"size" env-default:"10000"`
+	TTL  time.Duration `yaml:"ttl" env-default:"5m"`
+}
+
+// ReconcileConfig represents the periodic cache and db consistency check
+type ReconcileConfig struct {
+	// 0 runs it only by the reconcile command
+	Interval time.Duration `yaml:"interval" env-default:"0"`
+}
+
+// ValidationConfig represents what is done with orders failing validation
+type ValidationConfig struct {
+	// "reject", "warn" or "quarantine"
+	Mode string `yaml:"mode" env-default:"reject"`
+}
+
+// TracingConfig represents where spans are exported
+type TracingConfig struct {
+	// "none", "stdout", "file" or "otlp"
+	Exporter string `yaml:"exporter" env-default:"none"`
+	// OTLP/HTTP collector address
+	Endpoint string `yaml:"endpoint" env-default:"localhost:4318"`
+	// spans are appended to it by the file exporter
+	File        string  `yaml:"file" env-default:"./traces.json"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
 }
 
 // Config represents the overall configuration
 type Config struct {
-	App      AppConfig      `yaml:"app"`
-	Postgres PostgresConfig `yaml:"postgres"`
-	HTTP     HTTPConfig     `yaml:"http"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
-	Redis    RedisConfig    `yaml:"redis"`
+	App       AppConfig       `yaml:"app"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Postgres  PostgresConfig  `yaml:"postgres"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Reconcile ReconcileConfig `yaml:"reconcile"`
+
+	Validation ValidationConfig `yaml:"validation"`
+	Tracing    TracingConfig    `yaml:"tracing"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,284 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/dlq"
+	"github.com/EgorcaA/create_db/internal/feed"
+	"github.com/EgorcaA/create_db/internal/handler"
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/retry"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"github.com/EgorcaA/create_db/internal/validation"
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// delay before a message that failed with a transient error is handled again
+var retryDelay = time.Second
+
+// pause before the group is joined again after Consume failed,
+// it grows while Consume keeps failing
+var rejoinBackoff = retry.Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second, Jitter: 0.2}
+
+// OrderConsumer handles messages of the orders topic for a consumer group session
+type OrderConsumer struct {
+	log *slog.Logger
+	rdb redisclient.CacheClient
+	db  storage.Database
+	dlq *dlq.Publisher
+
+	retries handler.Retries
+
+	validationMode string
+	quarantine     *dlq.Publisher
+
+	// live feed of stored orders
+	feed *feed.Broadcaster
+
+	status groupStatus
+}
+
+func NewOrderConsumer(log *slog.Logger, rdb redisclient.CacheClient, db storage.Database,
+	dlqPublisher *dlq.Publisher, retries handler.Retries,
+	validation_conf config.ValidationConfig, quarantine *dlq.Publisher, broadcaster *feed.Broadcaster) *OrderConsumer {
+	return &OrderConsumer{
+		log:            log,
+		rdb:            rdb,
+		db:             db,
+		dlq:            dlqPublisher,
+		retries:        retries,
+		validationMode: validation_conf.Mode,
+		quarantine:     quarantine,
+		feed:           broadcaster,
+	}
+}
+
+func newSaramaConfig(kafka_conf config.KafkaConfig) *sarama.Config {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Consumer.Return.Errors = true
+	// new group starts from the beginning so nothing produced before the first start is lost
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	switch kafka_conf.RebalanceStrategy {
+	case "roundrobin":
+		saramaConfig.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		saramaConfig.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		saramaConfig.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	}
+	return saramaConfig
+}
+
+// NewConsumerGroup creates sarama consumer group described by kafka config
+func NewConsumerGroup(kafka_conf config.KafkaConfig) (sarama.ConsumerGroup, error) {
+	brokers := []string{kafka_conf.BootstrapServers}
+	return sarama.NewConsumerGroup(brokers, kafka_conf.GroupID, newSaramaConfig(kafka_conf))
+}
+
+// Run joins the group and consumes topics until ctx is cancelled.
+// Consume returns on every rebalance, so it is called in a loop.
+func Run(ctx context.Context, log *slog.Logger, group sarama.ConsumerGroup, topics []string, h sarama.ConsumerGroupHandler) {
+	go func() {
+		for err := range group.Errors() {
+			log.Warn(fmt.Sprintf("Kafka error: %v", err))
+		}
+	}()
+
+	failures := 0
+	for {
+		err := group.Consume(ctx, topics, h)
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			log.Info("Consumer group closed, exiting")
+			return
+		}
+		if ctx.Err() != nil {
+			log.Info("Context cancelled, stopping consumer")
+			return
+		}
+		if err == nil {
+			failures = 0
+			log.Info("Consumer group rebalancing")
+			continue
+		}
+
+		failures++
+		delay := rejoinBackoff.Delay(failures)
+		log.Error(fmt.Sprintf("Consumer group error: %v", err), slog.Duration("retry_in", delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info("Context cancelled, stopping consumer")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (c *OrderConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.status.setup(session)
+	for topic, partitions := range session.Claims() {
+		c.log.Info("Partitions assigned",
+			slog.String("topic", topic),
+			slog.Any("partitions", partitions),
+			slog.Int("generation", int(session.GenerationID())))
+	}
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
+func (c *OrderConsumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	// flush marked offsets before partitions go to another member
+	session.Commit()
+	c.status.cleanup()
+	c.log.Info("Partitions released", slog.Int("generation", int(session.GenerationID())))
+	return nil
+}
+
+// ConsumeClaim reads messages of a single partition claim
+func (c *OrderConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	lag := c.status.track(claim)
+	defer lag.close()
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				c.log.Debug("Messages channel closed", slog.Int("partition", int(claim.Partition())))
+				return nil
+			}
+			if !c.process(session.Context(), msg) {
+				// session is over, the message stays uncommitted and will be redelivered
+				return nil
+			}
+			session.MarkMessage(msg, "")
+			lag.handled(msg)
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process handles the message until it gets a committable result.
+// Transient failures block the partition, since committing a later offset would
+// commit this one as well. Returns false if ctx is done before that.
+func (c *OrderConsumer) process(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, msg), msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.destination.partition.id", int(msg.Partition)),
+			attribute.Int64("messaging.kafka.offset", msg.Offset)))
+	defer span.End()
+
+	metrics.Message(msg.Topic, msg.Partition, metrics.StageConsumed)
+	order, decodeErr := decode(msg)
+	if decodeErr == nil {
+		metrics.Message(msg.Topic, msg.Partition, metrics.StageDecoded)
+		span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+	} else {
+		tracing.RecordError(span, decodeErr)
+	}
+
+	for {
+		result, err := handler.ResultInvalid, decodeErr
+		if decodeErr == nil {
+			result, err = c.handle(ctx, order)
+		}
+		switch result {
+		case handler.ResultInvalid:
+			result = c.republish(ctx, c.dlq, msg, result, err)
+		case handler.ResultQuarantined:
+			result = c.republish(ctx, c.quarantine, msg, result, err)
+		}
+		if result.Commit() {
+			span.SetAttributes(attribute.String("result", result.String()))
+			switch result {
+			case handler.ResultStored:
+				metrics.Message(msg.Topic, msg.Partition, metrics.StageStored)
+			case handler.ResultInvalid, handler.ResultQuarantined:
+				metrics.Message(msg.Topic, msg.Partition, metrics.StageRejected)
+			}
+			c.log.Debug("Message processed",
+				slog.String("result", result.String()),
+				slog.Int("partition", int(msg.Partition)),
+				slog.Int64("offset", msg.Offset))
+			return true
+		}
+		span.AddEvent("retry", trace.WithAttributes(attribute.String("error", fmt.Sprint(err))))
+		c.log.Warn("Message processing failed, will retry",
+			slog.Int("partition", int(msg.Partition)),
+			slog.Int64("offset", msg.Offset))
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// republish puts rejected message aside, it may be committed only once it is safe in the other topic
+func (c *OrderConsumer) republish(ctx context.Context, p *dlq.Publisher, msg *sarama.ConsumerMessage,
+	result handler.Result, reason error) handler.Result {
+	if err := p.Publish(ctx, msg, reason.Error()); err != nil {
+		c.log.Error(fmt.Sprintf("Error republishing the message: %v", err),
+			slog.Int("partition", int(msg.Partition)),
+			slog.Int64("offset", msg.Offset))
+		return handler.ResultTransientFailure
+	}
+	c.log.Warn(fmt.Sprintf("Message is republished: %v", reason),
+		slog.String("topic", p.Topic()),
+		slog.Int("partition", int(msg.Partition)),
+		slog.Int64("offset", msg.Offset))
+	return result
+}
+
+func decode(msg *sarama.ConsumerMessage) (order_struct.Order, error) {
+	var order order_struct.Order
+	if msg.Value == nil {
+		return order, errors.New("message value is nil")
+	}
+	if err := json.Unmarshal(msg.Value, &order); err != nil {
+		return order, fmt.Errorf("error unmarshal the message: %w", err)
+	}
+	return order, nil
+}
+
+func (c *OrderConsumer) handle(ctx context.Context, order order_struct.Order) (handler.Result, error) {
+	_, span := tracing.Tracer().Start(ctx, "validate")
+	violations := validation.Validate(order)
+	span.SetAttributes(attribute.Int("violations", len(violations)))
+	span.End()
+
+	if violations != nil {
+		switch c.validationMode {
+		case validation.ModeWarn:
+			c.log.Warn(violations.Error(), slog.String("OrderUID", order.OrderUID))
+		case validation.ModeQuarantine:
+			return handler.ResultQuarantined, violations
+		default:
+			return handler.ResultInvalid, violations
+		}
+	}
+	result, err := handler.Handle_message(c.log, ctx, c.rdb, order, c.db, c.retries)
+	if result == handler.ResultStored {
+		c.feed.Publish(order)
+	}
+	return result, err
+}
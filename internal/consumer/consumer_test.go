@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/dlq"
+	"github.com/EgorcaA/create_db/internal/feed"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/handler"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/retry"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGroup fails every Consume call
+type fakeGroup struct {
+	sarama.ConsumerGroup
+	calls []time.Time
+}
+
+func (g *fakeGroup) Errors() <-chan error { return nil }
+func (g *fakeGroup) Consume(context.Context, []string, sarama.ConsumerGroupHandler) error {
+	g.calls = append(g.calls, time.Now())
+	return errors.New("broker is down")
+}
+
+func TestRunBackoff(t *testing.T) {
+
+	backoff := rejoinBackoff
+	rejoinBackoff = retry.Policy{BaseDelay: 20 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+	defer func() { rejoinBackoff = backoff }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	group := &fakeGroup{}
+
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, slogdiscard.NewDiscardLogger(), group, []string{"orders"}, &OrderConsumer{})
+		close(done)
+	}()
+
+	// Assert expectations
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once ctx was done")
+	}
+	// 0, 20, 60, 100, 140ms
+	assert.LessOrEqual(t, len(group.calls), 5)
+	assert.GreaterOrEqual(t, len(group.calls), 3)
+	for i := 1; i < len(group.calls); i++ {
+		assert.GreaterOrEqual(t, group.calls[i].Sub(group.calls[i-1]), 20*time.Millisecond)
+	}
+}
+
+func TestConsumeClaimMarks(t *testing.T) {
+
+	delay := retryDelay
+	retryDelay = 10 * time.Millisecond
+	defer func() { retryDelay = delay }()
+
+	stored := generator.GenerateFakeOrder()
+	duplicate := generator.GenerateFakeOrder()
+	invalid := generator.GenerateFakeOrder()
+	session := &fakeSession{ctx: context.Background()}
+
+	db := mocksdb.NewDatabase(t)
+	// nothing is marked while the first message keeps failing
+	notMarked := func(mock.Arguments) { assert.Empty(t, session.marked) }
+	db.On("InsertOrder", mock.Anything, stored).Return(storage.InsertResult(0), errors.New("connection refused")).
+		Twice().Run(notMarked)
+	db.On("InsertOrder", mock.Anything, stored).Return(storage.InsertCreated, nil).Once().Run(notMarked)
+	db.On("InsertOrder", mock.Anything, duplicate).Return(storage.InsertResult(0), storage.ErrDuplicateOrder).Once()
+	db.On("InsertOrder", mock.Anything, invalid).Return(storage.InsertResult(0), storage.ErrInvalidOrder).Once()
+	rdb := mocksredis.NewCacheClient(t)
+	rdb.On("SaveOrder", mock.Anything, stored).Return(nil).Once()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageAndSucceed()
+	c := NewOrderConsumer(slogdiscard.NewDiscardLogger(), rdb, db,
+		dlq.NewPublisherFromProducer(producer, "orders-dlq"), handler.Retries{},
+		config.ValidationConfig{}, nil, feed.NewBroadcaster())
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for offset, order := range []order_struct.Order{stored, duplicate, invalid} {
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+		claim.messages <- &sarama.ConsumerMessage{Topic: claim.Topic(), Offset: int64(offset), Value: value}
+	}
+	close(claim.messages)
+
+	// Assert expectations
+	require.NoError(t, c.ConsumeClaim(session, claim))
+	assert.Equal(t, []int64{0, 1, 2}, session.marked)
+}
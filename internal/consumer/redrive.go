@@ -0,0 +1,130 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/IBM/sarama"
+)
+
+// redriveHandler feeds dead-lettered messages back through OrderConsumer.
+// It stops at the end offsets seen at start, so messages dead-lettered again
+// during the run are left for the next one.
+type redriveHandler struct {
+	*OrderConsumer
+	oldest map[int32]int64
+	stop   map[int32]int64
+
+	mu     sync.Mutex
+	done   map[int32]bool
+	cancel context.CancelFunc
+}
+
+// Redrive re-handles every message of the dead-letter topic produced before the call
+func Redrive(ctx context.Context, log *slog.Logger, kafka_conf config.KafkaConfig, h *OrderConsumer) error {
+	brokers := []string{kafka_conf.BootstrapServers}
+	topic := kafka_conf.DeadLetterTopic
+
+	client, err := sarama.NewClient(brokers, newSaramaConfig(kafka_conf))
+	if err != nil {
+		return fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return fmt.Errorf("failed to get partitions of %s: %w", topic, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rh := &redriveHandler{
+		OrderConsumer: h,
+		oldest:        make(map[int32]int64, len(partitions)),
+		stop:          make(map[int32]int64, len(partitions)),
+		done:          make(map[int32]bool, len(partitions)),
+		cancel:        cancel,
+	}
+	total := int64(0)
+	for _, p := range partitions {
+		if rh.oldest[p], err = client.GetOffset(topic, p, sarama.OffsetOldest); err != nil {
+			return fmt.Errorf("failed to get oldest offset of %s/%d: %w", topic, p, err)
+		}
+		if rh.stop[p], err = client.GetOffset(topic, p, sarama.OffsetNewest); err != nil {
+			return fmt.Errorf("failed to get newest offset of %s/%d: %w", topic, p, err)
+		}
+		total += rh.stop[p] - rh.oldest[p]
+	}
+	log.Info("Redriving dead-letter topic",
+		slog.String("topic", topic),
+		slog.Int("partitions", len(partitions)),
+		slog.Int64("retained messages", total))
+	if total == 0 {
+		return nil
+	}
+
+	group, err := sarama.NewConsumerGroupFromClient(kafka_conf.GroupID+"-redrive", client)
+	if err != nil {
+		return fmt.Errorf("failed creating redrive consumer group: %w", err)
+	}
+	defer group.Close()
+
+	Run(ctx, log, group, []string{topic}, rh)
+	log.Info("Redrive finished")
+	return nil
+}
+
+func (rh *redriveHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	p := claim.Partition()
+	stop := rh.stop[p]
+
+	start := claim.InitialOffset()
+	if start < 0 {
+		start = rh.oldest[p]
+	}
+	if start >= stop {
+		return rh.finish(session, p)
+	}
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if msg.Offset >= stop {
+				return rh.finish(session, p)
+			}
+			if !rh.process(session.Context(), msg) {
+				return nil
+			}
+			session.MarkMessage(msg, "")
+			if msg.Offset+1 >= stop {
+				return rh.finish(session, p)
+			}
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// finish marks partition as redriven and stops the run once all of them are.
+// The claim is held until then: the first ConsumeClaim to return ends the session
+// and the group would rebalance, handing the partition out again.
+func (rh *redriveHandler) finish(session sarama.ConsumerGroupSession, p int32) error {
+	rh.mu.Lock()
+	rh.done[p] = true
+	rh.log.Info("Partition redriven", slog.Int("partition", int(p)))
+	if len(rh.done) == len(rh.stop) {
+		rh.cancel()
+	}
+	rh.mu.Unlock()
+
+	<-session.Context().Done()
+	return nil
+}
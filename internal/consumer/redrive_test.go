@@ -0,0 +1,111 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/dlq"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSession struct {
+	ctx    context.Context
+	claims map[string][]int32
+
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32               { return s.claims }
+func (s *fakeSession) MemberID() string                         { return "member" }
+func (s *fakeSession) GenerationID() int32                      { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)  {}
+func (s *fakeSession) Commit()                                  {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string) {}
+func (s *fakeSession) Context() context.Context                 { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	partition int32
+	initial   int64
+	hwm       atomic.Int64
+	messages  chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "orders-dlq" }
+func (c *fakeClaim) Partition() int32                         { return c.partition }
+func (c *fakeClaim) InitialOffset() int64                     { return c.initial }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return c.hwm.Load() }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// claim with undecodable messages at offsets [0, n), they all go to the dead-letter topic again
+func newFakeClaim(partition int32, n int) *fakeClaim {
+	c := &fakeClaim{partition: partition, initial: sarama.OffsetNewest, messages: make(chan *sarama.ConsumerMessage, n)}
+	c.hwm.Store(int64(n))
+	for offset := range n {
+		c.messages <- &sarama.ConsumerMessage{Topic: c.Topic(), Partition: partition, Offset: int64(offset)}
+	}
+	return c
+}
+
+func TestRedriveStop(t *testing.T) {
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	publisher := dlq.NewPublisherFromProducer(producer, "orders-dlq")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := &fakeSession{ctx: ctx}
+
+	// partition 0 has nothing to redrive, partition 1 has three of five messages
+	rh := &redriveHandler{
+		OrderConsumer: &OrderConsumer{log: slogdiscard.NewDiscardLogger(), dlq: publisher},
+		oldest:        map[int32]int64{0: 0, 1: 0},
+		stop:          map[int32]int64{0: 0, 1: 3},
+		done:          make(map[int32]bool),
+		cancel:        cancel,
+	}
+
+	consume := func(claim sarama.ConsumerGroupClaim) <-chan error {
+		returned := make(chan error, 1)
+		go func() { returned <- rh.ConsumeClaim(session, claim) }()
+		return returned
+	}
+
+	// finished partition holds its claim while the run goes on
+	empty := consume(newFakeClaim(0, 0))
+	select {
+	case <-empty:
+		t.Fatal("claim of a redriven partition returned before the run ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for range 3 {
+		producer.ExpectSendMessageAndSucceed()
+	}
+	busy := consume(newFakeClaim(1, 5))
+
+	// Assert expectations
+	for _, returned := range []<-chan error{empty, busy} {
+		select {
+		case err := <-returned:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("redrive did not stop once all partitions were redriven")
+		}
+	}
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, []int64{0, 1, 2}, session.marked)
+}
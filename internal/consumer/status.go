@@ -0,0 +1,166 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/health"
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/IBM/sarama"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// groupStatus tracks partitions of the current session and how far behind they are
+type groupStatus struct {
+	mu     sync.Mutex
+	joined bool
+	lag    map[topicPartition]int64
+}
+
+// Status is the consumer part of the readiness report
+type Status struct {
+	Joined     bool  `json:"joined"`
+	Partitions int   `json:"partitions"`
+	Lag        int64 `json:"lag"`
+}
+
+func (s *groupStatus) setup(session sarama.ConsumerGroupSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joined = true
+	s.lag = make(map[topicPartition]int64)
+	for topic, partitions := range session.Claims() {
+		for _, p := range partitions {
+			s.lag[topicPartition{topic, p}] = 0
+		}
+	}
+}
+
+func (s *groupStatus) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joined = false
+	s.lag = nil
+	metrics.ResetConsumerLag()
+}
+
+// lagRefresh is how often the lag of a claim is refreshed from its high water mark
+var lagRefresh = 5 * time.Second
+
+// claimTracker keeps the lag of a claim up to date while it is consumed
+type claimTracker struct {
+	status *groupStatus
+	claim  sarama.ConsumerGroupClaim
+	// offset of the next message to handle
+	next atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// track starts tracking the claim lag. It is seeded from the initial offset and
+// refreshed on a ticker, so it keeps growing while a message is retried or
+// no message arrives at all.
+func (s *groupStatus) track(claim sarama.ConsumerGroupClaim) *claimTracker {
+	t := &claimTracker{status: s, claim: claim, stop: make(chan struct{}), done: make(chan struct{})}
+	t.next.Store(claim.InitialOffset())
+	t.refresh()
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(lagRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.refresh()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// handled records that msg is handled
+func (t *claimTracker) handled(msg *sarama.ConsumerMessage) {
+	t.next.Store(msg.Offset + 1)
+	t.refresh()
+}
+
+// close stops the refresh, it has to be called before the session cleanup
+func (t *claimTracker) close() {
+	close(t.stop)
+	<-t.done
+}
+
+func (t *claimTracker) refresh() {
+	hwm := t.claim.HighWaterMarkOffset()
+	if hwm > 0 {
+		// consumption starts at the high water mark seen first
+		t.next.CompareAndSwap(sarama.OffsetNewest, hwm)
+	}
+	var lag int64
+	switch next := t.next.Load(); next {
+	case sarama.OffsetNewest:
+	case sarama.OffsetOldest:
+		// retention may have dropped the head of the partition, so it is an upper bound
+		lag = hwm
+	default:
+		lag = hwm - next
+	}
+	if lag < 0 {
+		// high water mark is not fetched yet
+		lag = 0
+	}
+	t.status.update(t.claim.Topic(), t.claim.Partition(), lag)
+}
+
+// update records the lag of a partition of the current session
+func (s *groupStatus) update(topic string, partition int32, lag int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lag == nil {
+		return
+	}
+	s.lag[topicPartition{topic, partition}] = lag
+	metrics.ConsumerLag(topic, partition, lag)
+}
+
+func (s *groupStatus) get() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{Joined: s.joined, Partitions: len(s.lag)}
+	for _, lag := range s.lag {
+		status.Lag += lag
+	}
+	return status
+}
+
+// Status returns the state of the current consumer group session
+func (c *OrderConsumer) Status() Status {
+	return c.status.get()
+}
+
+// ReadyCheck reports the consumer as ready once it joined the group and
+// the total lag of its partitions is at most maxLag.
+// A member without partitions is ready, the group has more members than partitions.
+func (c *OrderConsumer) ReadyCheck(maxLag int64) health.Check {
+	return func(ctx context.Context) (any, error) {
+		status := c.Status()
+		if !status.Joined {
+			return status, fmt.Errorf("consumer has not joined group yet")
+		}
+		if status.Lag > maxLag {
+			return status, fmt.Errorf("consumer lag %d is above %d", status.Lag, maxLag)
+		}
+		return status, nil
+	}
+}
@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusLag(t *testing.T) {
+
+	refresh := lagRefresh
+	lagRefresh = 10 * time.Millisecond
+	defer func() { lagRefresh = refresh }()
+
+	// Define test cases
+	tests := []struct {
+		name        string
+		initial     int64
+		hwm         int64
+		expectedLag int64
+		// after 100 more messages are produced
+		expectedGrown int64
+	}{
+		{
+			name:          "Committed offset",
+			initial:       10,
+			hwm:           25,
+			expectedLag:   15,
+			expectedGrown: 115,
+		},
+		{
+			name:          "New group from the oldest offset",
+			initial:       sarama.OffsetOldest,
+			hwm:           25,
+			expectedLag:   25,
+			expectedGrown: 125,
+		},
+		{
+			name:          "New group from the newest offset",
+			initial:       sarama.OffsetNewest,
+			hwm:           25,
+			expectedLag:   0,
+			expectedGrown: 100,
+		},
+		{
+			name:          "High water mark is not fetched yet",
+			initial:       10,
+			hwm:           0,
+			expectedLag:   0,
+			expectedGrown: 90,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &OrderConsumer{}
+			claim := &fakeClaim{partition: 1, initial: tt.initial}
+			claim.hwm.Store(tt.hwm)
+			c.status.setup(&fakeSession{ctx: context.Background(),
+				claims: map[string][]int32{claim.Topic(): {claim.Partition()}}})
+			check := c.ReadyCheck(tt.expectedLag)
+
+			// lag is known before the first message
+			lag := c.status.track(claim)
+			assert.Equal(t, Status{Joined: true, Partitions: 1, Lag: tt.expectedLag}, c.Status())
+			_, err := check(context.Background())
+			assert.NoError(t, err)
+
+			// and follows the high water mark while nothing is handled
+			claim.hwm.Store(tt.hwm + 100)
+			require.Eventually(t, func() bool { return c.Status().Lag == tt.expectedGrown },
+				time.Second, lagRefresh)
+			_, err = check(context.Background())
+			assert.Error(t, err)
+
+			lag.handled(&sarama.ConsumerMessage{Topic: claim.Topic(), Partition: claim.Partition(), Offset: tt.hwm + 94})
+			assert.Equal(t, int64(5), c.Status().Lag)
+
+			lag.close()
+			c.status.cleanup()
+			assert.Equal(t, Status{}, c.Status())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"github.com/IBM/sarama"
+)
+
+// headers attached to dead-lettered messages
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderFailureReason     = "x-failure-reason"
+	HeaderAttemptCount      = "x-attempt-count"
+)
+
+// Publisher republishes rejected messages to the dead-letter (or quarantine) topic
+type Publisher struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewPublisher(kafka_conf config.KafkaConfig, topic string) (*Publisher, error) {
+	brokers := []string{kafka_conf.BootstrapServers}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+
+	producer, err := sarama.NewSyncProducer(brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer for %s: %w", topic, err)
+	}
+	return NewPublisherFromProducer(producer, topic), nil
+}
+
+// NewPublisherFromProducer creates publisher on top of an existing producer
+func NewPublisherFromProducer(producer sarama.SyncProducer, topic string) *Publisher {
+	return &Publisher{producer: producer, topic: topic}
+}
+
+func (p *Publisher) Topic() string {
+	return p.topic
+}
+
+// Publish sends msg to the publisher topic.
+// Messages that already come from the dead-letter topic keep their original
+// coordinates and get the attempt counter increased.
+func (p *Publisher) Publish(ctx context.Context, msg *sarama.ConsumerMessage, reason string) error {
+	origTopic := msg.Topic
+	origPartition := strconv.Itoa(int(msg.Partition))
+	origOffset := strconv.FormatInt(msg.Offset, 10)
+	attempts := 1
+
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case HeaderOriginalTopic:
+			origTopic = string(h.Value)
+		case HeaderOriginalPartition:
+			origPartition = string(h.Value)
+		case HeaderOriginalOffset:
+			origOffset = string(h.Value)
+		case HeaderAttemptCount:
+			if n, err := strconv.Atoi(string(h.Value)); err == nil {
+				attempts = n + 1
+			}
+		}
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic: p.topic,
+		Value: sarama.ByteEncoder(msg.Value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(HeaderOriginalTopic), Value: []byte(origTopic)},
+			{Key: []byte(HeaderOriginalPartition), Value: []byte(origPartition)},
+			{Key: []byte(HeaderOriginalOffset), Value: []byte(origOffset)},
+			{Key: []byte(HeaderFailureReason), Value: []byte(reason)},
+			{Key: []byte(HeaderAttemptCount), Value: []byte(strconv.Itoa(attempts))},
+		},
+	}
+	if msg.Key != nil {
+		message.Key = sarama.ByteEncoder(msg.Key)
+	}
+	// redriven message continues the trace of the rejected one
+	tracing.Inject(ctx, message)
+
+	if _, _, err := p.producer.SendMessage(message); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", p.topic, err)
+	}
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	return p.producer.Close()
+}
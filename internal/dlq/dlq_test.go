@@ -0,0 +1,107 @@
+package dlq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/dlq"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func header(key, value string) *sarama.RecordHeader {
+	return &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
+
+func TestPublish(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name            string
+		msg             *sarama.ConsumerMessage
+		sendErr         error
+		expectedHeaders map[string]string
+		expectedErr     bool
+	}{
+		{
+			name: "First failure",
+			msg: &sarama.ConsumerMessage{Topic: "orders", Partition: 2, Offset: 42,
+				Key: []byte("key"), Value: []byte("value")},
+			expectedHeaders: map[string]string{
+				dlq.HeaderOriginalTopic:     "orders",
+				dlq.HeaderOriginalPartition: "2",
+				dlq.HeaderOriginalOffset:    "42",
+				dlq.HeaderFailureReason:     "reason",
+				dlq.HeaderAttemptCount:      "1",
+			},
+		},
+		{
+			name: "Redriven message fails again",
+			msg: &sarama.ConsumerMessage{Topic: "orders-dlq", Partition: 0, Offset: 7,
+				Key: []byte("key"), Value: []byte("value"),
+				Headers: []*sarama.RecordHeader{
+					header(dlq.HeaderOriginalTopic, "orders"),
+					header(dlq.HeaderOriginalPartition, "2"),
+					header(dlq.HeaderOriginalOffset, "42"),
+					header(dlq.HeaderFailureReason, "first reason"),
+					header(dlq.HeaderAttemptCount, "2"),
+					nil,
+				}},
+			expectedHeaders: map[string]string{
+				dlq.HeaderOriginalTopic:     "orders",
+				dlq.HeaderOriginalPartition: "2",
+				dlq.HeaderOriginalOffset:    "42",
+				dlq.HeaderFailureReason:     "reason",
+				dlq.HeaderAttemptCount:      "3",
+			},
+		},
+		{
+			name:        "Send fails",
+			msg:         &sarama.ConsumerMessage{Topic: "orders", Value: []byte("value")},
+			sendErr:     errors.New("broker is down"),
+			expectedErr: true,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			var sent *sarama.ProducerMessage
+			capture := func(msg *sarama.ProducerMessage) error {
+				sent = msg
+				return nil
+			}
+			if tt.sendErr != nil {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(capture, tt.sendErr)
+			} else {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+			}
+			p := dlq.NewPublisherFromProducer(producer, "orders-dlq")
+
+			err := p.Publish(ctx, tt.msg, "reason")
+			require.NoError(t, p.Close())
+
+			// Assert expectations
+			if tt.expectedErr {
+				assert.ErrorIs(t, err, tt.sendErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, sent)
+			assert.Equal(t, "orders-dlq", sent.Topic)
+			assert.Equal(t, sarama.ByteEncoder(tt.msg.Key), sent.Key)
+			assert.Equal(t, sarama.ByteEncoder(tt.msg.Value), sent.Value)
+			headers := make(map[string]string)
+			for _, h := range sent.Headers {
+				headers[string(h.Key)] = string(h.Value)
+			}
+			assert.Equal(t, tt.expectedHeaders, headers)
+		})
+	}
+}
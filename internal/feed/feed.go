@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+)
+
+// Filter selects orders a subscriber is interested in, empty fields match everything
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+	Entry           string
+}
+
+func (f Filter) Match(order order_struct.Order) bool {
+	return (f.CustomerID == "" || f.CustomerID == order.CustomerID) &&
+		(f.DeliveryService == "" || f.DeliveryService == order.DeliveryService) &&
+		(f.Entry == "" || f.Entry == order.Entry)
+}
+
+// Subscriber receives published orders matching its filter
+type Subscriber struct {
+	C      <-chan order_struct.Order
+	c      chan order_struct.Order
+	filter Filter
+
+	dropped atomic.Int64
+}
+
+// Dropped returns the number of orders skipped because the subscriber was too slow
+func (s *Subscriber) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Broadcaster fans stored orders out to live feed subscribers.
+// Publish never blocks: a subscriber with a full buffer misses the order.
+type Broadcaster struct {
+	mu   sync.RWMutex
+	subs map[*Subscriber]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[*Subscriber]struct{})}
+}
+
+func (b *Broadcaster) Subscribe(filter Filter, buffer int) *Subscriber {
+	c := make(chan order_struct.Order, buffer)
+	s := &Subscriber{C: c, c: c, filter: filter}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (b *Broadcaster) Unsubscribe(s *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+func (b *Broadcaster) Publish(order order_struct.Order) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if !s.filter.Match(order) {
+			continue
+		}
+		select {
+		case s.c <- order:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscribers
+func (b *Broadcaster) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
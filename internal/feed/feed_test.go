@@ -0,0 +1,77 @@
+package feed_test
+
+import (
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/feed"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+
+	// Define test cases
+	tests := []struct {
+		name            string
+		filter          feed.Filter
+		buffer          int
+		published       int
+		expectedOrders  int
+		expectedDropped int64
+	}{
+		{
+			name:           "No filter",
+			filter:         feed.Filter{},
+			buffer:         1,
+			published:      1,
+			expectedOrders: 1,
+		},
+		{
+			name:           "Matching filter",
+			filter:         feed.Filter{CustomerID: order.CustomerID, Entry: order.Entry},
+			buffer:         1,
+			published:      1,
+			expectedOrders: 1,
+		},
+		{
+			name:           "Other customer",
+			filter:         feed.Filter{CustomerID: order.CustomerID + "-other"},
+			buffer:         1,
+			published:      1,
+			expectedOrders: 0,
+		},
+		{
+			name:            "Slow subscriber",
+			filter:          feed.Filter{},
+			buffer:          2,
+			published:       5,
+			expectedOrders:  2,
+			expectedDropped: 3,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := feed.NewBroadcaster()
+			sub := b.Subscribe(tt.filter, tt.buffer)
+
+			for range tt.published {
+				b.Publish(order)
+			}
+			b.Unsubscribe(sub)
+
+			received := 0
+			for range sub.C {
+				received++
+			}
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedOrders, received)
+			assert.Equal(t, tt.expectedDropped, sub.Dropped())
+			assert.Equal(t, 0, b.Subscribers())
+		})
+	}
+}
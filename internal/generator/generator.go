@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -8,12 +9,21 @@ import (
 
 	"github.com/EgorcaA/create_db/internal/config"
 	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"github.com/EgorcaA/create_db/internal/validation"
 	"github.com/IBM/sarama"
 	"github.com/brianvoe/gofakeit/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// generates fake order structure
+// generates fake order structure, totals are consistent so it passes validation
 func GenerateFakeOrder() order_struct.Order {
+	price := gofakeit.Number(100, 1000)
+	sale := gofakeit.Number(0, 50)
+	totalPrice := validation.ItemTotal(price, sale)
+	deliveryCost := gofakeit.Number(100, 2000)
+
 	return order_struct.Order{
 		OrderUID:    gofakeit.UUID(),
 		TrackNumber: gofakeit.UUID(),
@@ -32,23 +42,23 @@ func GenerateFakeOrder() order_struct.Order {
 			RequestID:    "",
 			Currency:     "USD",
 			Provider:     "wbpay",
-			Amount:       gofakeit.Number(100, 5000),
+			Amount:       totalPrice + deliveryCost,
 			PaymentDT:    gofakeit.Date().Unix(),
 			Bank:         gofakeit.Company(),
-			DeliveryCost: gofakeit.Number(100, 2000),
-			GoodsTotal:   gofakeit.Number(50, 2000),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   totalPrice,
 			CustomFee:    0,
 		},
 		Items: []order_struct.Item{
 			{
 				ChrtID:      gofakeit.Number(1000000, 9999999),
 				TrackNumber: gofakeit.UUID(),
-				Price:       gofakeit.Number(100, 1000),
+				Price:       price,
 				RID:         gofakeit.UUID(),
 				Name:        gofakeit.Word(),
-				Sale:        gofakeit.Number(0, 50),
+				Sale:        sale,
 				Size:        gofakeit.Word(),
-				TotalPrice:  gofakeit.Number(50, 500),
+				TotalPrice:  totalPrice,
 				NmID:        gofakeit.Number(100000, 999999),
 				Brand:       gofakeit.Company(),
 				Status:      gofakeit.Number(1, 10),
@@ -100,20 +110,31 @@ func Spam_kafka(log *slog.Logger, kafka_conf config.KafkaConfig) {
 
 	// Create a message
 	for a := 0; a < 5; a++ {
-		orderJSON, err := json.Marshal(GenerateFakeOrder())
+		order := GenerateFakeOrder()
+		orderJSON, err := json.Marshal(order)
 		if err != nil {
 			log.Error(fmt.Sprintf("Failed to serialize order for kafka producer: %v", err))
 		} else {
+			// every order starts its own trace, the consumer continues it from headers
+			ctx, span := tracing.Tracer().Start(context.Background(), kafka_conf.Topic+" publish",
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "kafka"),
+					attribute.String("messaging.destination.name", kafka_conf.Topic),
+					attribute.String("order.uid", order.OrderUID)))
 			message := &sarama.ProducerMessage{
 				Topic: kafka_conf.Topic,
 				Value: sarama.StringEncoder(orderJSON),
 			}
+			tracing.Inject(ctx, message)
 
 			// Send the message
 			partition, offset, err := producer.SendMessage(message)
 			if err != nil {
 				log.Warn(fmt.Sprintf("Failed to send message: %v", err))
 			}
+			tracing.RecordError(span, err)
+			span.End()
 
 			// Success confirmation
 			log.Debug(fmt.Sprintf("Message sent to partition %d with offset %d\n", partition, offset))
@@ -2,35 +2,109 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/EgorcaA/create_db/internal/order_struct"
 	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/retry"
 	"github.com/EgorcaA/create_db/internal/storage"
 )
 
-// main kafka messages handler
+// Result tells the consumer what happened with the message
+type Result int
+
+const (
+	// order is saved in DB
+	ResultStored Result = iota
+	// order was already in DB
+	ResultDuplicate
+	// order is rejected and will never be stored
+	ResultInvalid
+	// order is not stored, message has to be redelivered
+	ResultTransientFailure
+	// order failed validation and is put aside for review
+	ResultQuarantined
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultStored:
+		return "stored"
+	case ResultDuplicate:
+		return "duplicate"
+	case ResultInvalid:
+		return "invalid"
+	case ResultTransientFailure:
+		return "transient failure"
+	case ResultQuarantined:
+		return "quarantined"
+	}
+	return fmt.Sprintf("Result(%d)", int(r))
+}
+
+// Commit reports whether the message offset may be committed
+func (r Result) Commit() bool {
+	return r != ResultTransientFailure
+}
+
+// Retries holds retry policies for transient failures of each dependency
+type Retries struct {
+	DB    retry.Policy
+	Cache retry.Policy
+}
+
+// main kafka messages handler,
+// returned error is the cause of ResultInvalid and ResultTransientFailure
 func Handle_message(log *slog.Logger, ctx context.Context,
-	rdb redisclient.CacheClient, msg order_struct.Order, db storage.Database) {
+	rdb redisclient.CacheClient, msg order_struct.Order, db storage.Database, retries Retries) (Result, error) {
 
 	log.Debug("Got new message", slog.String("OrderUID", msg.OrderUID))
 
 	// Saving to db
-	err := db.InsertOrder(ctx, msg)
+	var inserted storage.InsertResult
+	err := retry.Do(ctx, retries.DB, storage.IsTransient, func() error {
+		var err error
+		inserted, err = db.InsertOrder(ctx, msg)
+		if storage.IsTransient(err) {
+			log.Debug(fmt.Sprintf("Transient error saving order in DB: %v", err),
+				slog.String("OrderUID", msg.OrderUID))
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, storage.ErrDuplicateOrder):
+		// stored order differs and is kept, cache already holds it
+		log.Warn(fmt.Sprintf("Order is already in DB: %v", err),
+			slog.String("OrderUID", msg.OrderUID))
+		return ResultDuplicate, nil
+	case errors.Is(err, storage.ErrInvalidOrder):
+		log.Warn(fmt.Sprintf("Order is rejected by DB: %v", err),
+			slog.String("OrderUID", msg.OrderUID))
+		return ResultInvalid, err
+	case err != nil:
+		log.Warn(fmt.Sprintf("Error saving order in DB: %v", err),
+			slog.String("OrderUID", msg.OrderUID))
+		return ResultTransientFailure, err
+	}
+	log.Debug("Order is saved in DB",
+		slog.String("OrderUID", msg.OrderUID),
+		slog.String("result", inserted.String()))
+
+	// Cache the order in Redis, also on redelivery since the first attempt may have missed it.
+	// The order is durable already so a failure here is not fatal
+	err = retry.Do(ctx, retries.Cache, redisclient.IsTransient, func() error {
+		return rdb.SaveOrder(ctx, msg)
+	})
 	if err != nil {
-		log.Debug(fmt.Sprintf("Error saving order in DB: %v", err),
+		log.Warn(fmt.Sprintf("Error saving order in Cache: %v", err),
 			slog.String("OrderUID", msg.OrderUID))
 	} else {
-		log.Debug("Order is saved in DB", slog.String("OrderUID", msg.OrderUID))
-
-		// Cache the order in Redis
-		err = rdb.SaveOrder(ctx, msg)
-		if err != nil {
-			log.Warn(fmt.Sprintf("Order is saved in Cache: %+v", msg),
-				slog.String("OrderUID", msg.OrderUID))
-		} else {
-			log.Debug("Order is saved in Cache", slog.String("OrderUID", msg.OrderUID))
-		}
+		log.Debug("Order is saved in Cache", slog.String("OrderUID", msg.OrderUID))
+	}
+	if inserted == storage.InsertUnchanged {
+		return ResultDuplicate, nil
 	}
+	return ResultStored, nil
 }
@@ -3,6 +3,9 @@ package handler_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
+	"syscall"
 	"testing"
 
 	"github.com/EgorcaA/create_db/internal/generator"
@@ -10,6 +13,9 @@ import (
 	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
 	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
 	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/retry"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestHandle_message(t *testing.T) {
@@ -21,38 +27,104 @@ func TestHandle_message(t *testing.T) {
 
 	ctx := context.Background()
 
+	retries := handler.Retries{
+		DB:    retry.Policy{MaxAttempts: 3},
+		Cache: retry.Policy{MaxAttempts: 2},
+	}
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
 	// Define test cases
 	tests := []struct {
 		name           string
 		mockDBSetup    func(mockDB *mocksdb.Database)
 		mockCacheSetup func(mockCache *mocksredis.CacheClient)
+		expected       handler.Result
 	}{
 		{
 			name: "Successful order save and cache",
 			mockDBSetup: func(mockDB *mocksdb.Database) {
-				mockDB.On("InsertOrder", ctx, msg).Return(nil)
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertCreated, nil)
 			},
 			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
 				mockCache.On("SaveOrder", ctx, msg).Return(nil)
 			},
+			expected: handler.ResultStored,
 		},
 		{
 			name: "DB save fails",
 			mockDBSetup: func(mockDB *mocksdb.Database) {
-				mockDB.On("InsertOrder", ctx, msg).Return(errors.New("DB error"))
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertResult(0), errors.New("DB error"))
 			},
 			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
 				// No cache interaction since DB save fails
 			},
+			expected: handler.ResultTransientFailure,
+		},
+		{
+			name: "Transient DB error is retried",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertResult(0), connRefused).Once()
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertCreated, nil).Once()
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("SaveOrder", ctx, msg).Return(nil)
+			},
+			expected: handler.ResultStored,
+		},
+		{
+			name: "Transient DB error exhausts attempts",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertResult(0), connRefused).Times(3)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expected:       handler.ResultTransientFailure,
+		},
+		{
+			name: "Transient cache error is retried",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertCreated, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("SaveOrder", ctx, msg).Return(connRefused).Once()
+				mockCache.On("SaveOrder", ctx, msg).Return(nil).Once()
+			},
+			expected: handler.ResultStored,
+		},
+		{
+			name: "Changed order is kept in DB",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertResult(0), fmt.Errorf("%w: stored order differs", storage.ErrDuplicateOrder))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expected:       handler.ResultDuplicate,
+		},
+		{
+			name: "Redelivered order is cached again",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertUnchanged, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("SaveOrder", ctx, msg).Return(nil)
+			},
+			expected: handler.ResultDuplicate,
+		},
+		{
+			name: "Order rejected by DB",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertResult(0), fmt.Errorf("%w: not null violation", storage.ErrInvalidOrder))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expected:       handler.ResultInvalid,
 		},
 		{
 			name: "Cache save fails",
 			mockDBSetup: func(mockDB *mocksdb.Database) {
-				mockDB.On("InsertOrder", ctx, msg).Return(nil)
+				mockDB.On("InsertOrder", ctx, msg).Return(storage.InsertCreated, nil)
 			},
 			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
 				mockCache.On("SaveOrder", ctx, msg).Return(errors.New("Cache error"))
 			},
+			expected: handler.ResultStored,
 		},
 	}
 
@@ -68,7 +140,9 @@ func TestHandle_message(t *testing.T) {
 			tt.mockCacheSetup(mockCache)
 
 			// Call the function
-			handler.Handle_message(logger, ctx, mockCache, msg, mockDB)
+			result, err := handler.Handle_message(logger, ctx, mockCache, msg, mockDB, retries)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, result == handler.ResultInvalid || result == handler.ResultTransientFailure, err != nil)
 
 			// Assert expectations
 			mockDB.AssertExpectations(t)
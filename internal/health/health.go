@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check reports whether a dependency is usable, detail is added to the report as is
+type Check func(ctx context.Context) (detail any, err error)
+
+// CheckResult is the state of a single dependency
+type CheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Detail    any    `json:"detail,omitempty"`
+}
+
+// Report is the JSON body of the readiness endpoint
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+// Checker runs named dependency checks
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewChecker returns a checker giving every check at most timeout to answer
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs all checks concurrently, the report is up only if every check is up
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			detail, err := check(ctx)
+			result := CheckResult{Status: StatusUp, LatencyMS: time.Since(start).Milliseconds(), Detail: detail}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// Flag is a check of a one-off startup step, it is down until Set is called
+type Flag struct {
+	done    atomic.Bool
+	pending string
+}
+
+func NewFlag(pending string) *Flag {
+	return &Flag{pending: pending}
+}
+
+func (f *Flag) Set() {
+	f.done.Store(true)
+}
+
+func (f *Flag) Check(ctx context.Context) (any, error) {
+	if !f.done.Load() {
+		return nil, errors.New(f.pending)
+	}
+	return nil, nil
+}
@@ -0,0 +1,226 @@
+package memcache
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats of the in-process cache
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+}
+
+// time the shared read of a missed order is given
+const fillTimeout = 5 * time.Second
+
+type entry struct {
+	order   order_struct.Order
+	expires time.Time
+}
+
+// Cache is an in-process LRU CacheClient bounded by size and ttl.
+// It works standalone or in front of another CacheClient, writes go through
+// to it and concurrent misses of one order are collapsed into a single read.
+type Cache struct {
+	next redisclient.CacheClient
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // front is the most recently used
+	entries map[string]*list.Element
+
+	group singleflight.Group
+	// write generations of orders read from the next tier at the moment,
+	// bumped by SaveOrder, Evict and Flush
+	filling map[string]uint64
+
+	hits, misses, evictions atomic.Int64
+}
+
+// New returns in-process cache, next is nil when it runs standalone
+func New(cache_conf config.CacheConfig, next redisclient.CacheClient) *Cache {
+	return &Cache{
+		next:    next,
+		size:    cache_conf.Size,
+		ttl:     cache_conf.TTL,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		filling: make(map[string]uint64),
+	}
+}
+
+func (c *Cache) GetOrder(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	if order, ok := c.get(orderUID); ok {
+		c.hits.Add(1)
+		metrics.MemoryCache("hit")
+		return order, nil
+	}
+	c.misses.Add(1)
+	metrics.MemoryCache("miss")
+	if c.next == nil {
+		return order_struct.Order{}, redisclient.ErrCacheMiss
+	}
+
+	// readers of the same order share one read, a reader that is gone doesn't cancel it
+	ch := c.group.DoChan(orderUID, func() (any, error) {
+		return c.fill(context.WithoutCancel(ctx), orderUID)
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(order_struct.Order), res.Err
+	case <-ctx.Done():
+		return order_struct.Order{}, ctx.Err()
+	}
+}
+
+// fill reads the order from the next tier and caches it. An order saved or evicted
+// during the read is not cached, the read may have returned its previous state.
+func (c *Cache) fill(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, fillTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	c.filling[orderUID] = 0
+	c.mu.Unlock()
+
+	order, err := c.next.GetOrder(ctx, orderUID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen := c.filling[orderUID]
+	delete(c.filling, orderUID)
+	if err == nil && gen == 0 {
+		c.insert(order, true)
+	}
+	return order, err
+}
+
+// SaveOrder caches the order in memory and writes it through to the next tier
+func (c *Cache) SaveOrder(ctx context.Context, order order_struct.Order) error {
+	c.mu.Lock()
+	c.bump(order.OrderUID)
+	c.insert(order, true)
+	c.mu.Unlock()
+	if c.next == nil {
+		return nil
+	}
+	return c.next.SaveOrder(ctx, order)
+}
+
+// RestoreCacheFromDB restores the next tier, the in-process one fills up on reads.
+// Standalone cache loads as many of the newest orders as it holds.
+func (c *Cache) RestoreCacheFromDB(ctx context.Context, log *slog.Logger, src redisclient.OrderSource) (int, error) {
+	if c.next != nil {
+		return c.next.RestoreCacheFromDB(ctx, log, src)
+	}
+	return redisclient.RestoreOrders(ctx, log, src, time.Time{}, c.size,
+		func(ctx context.Context, order order_struct.Order) error {
+			// orders come newest first, older ones go behind them
+			c.add(order, false)
+			return nil
+		})
+}
+
+// Evict drops the local copy of the order, it is read again from the next tier
+func (c *Cache) Evict(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bump(orderUID)
+	if el, ok := c.entries[orderUID]; ok {
+		c.remove(el)
+	}
+}
+
+// Flush drops every local copy
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	clear(c.entries)
+	for orderUID := range c.filling {
+		c.bump(orderUID)
+	}
+}
+
+// bump marks the order changed for a read from the next tier in progress
+func (c *Cache) bump(orderUID string) {
+	if gen, ok := c.filling[orderUID]; ok {
+		c.filling[orderUID] = gen + 1
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+func (c *Cache) get(orderUID string) (order_struct.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[orderUID]
+	if !ok {
+		return order_struct.Order{}, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.remove(el)
+		return order_struct.Order{}, false
+	}
+	c.lru.MoveToFront(el)
+	return e.order, true
+}
+
+// add puts the order to the front of the lru, or to the back when it is less used than the cached ones
+func (c *Cache) add(order order_struct.Order, front bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(order, front)
+}
+
+// insert is add with the lock held
+func (c *Cache) insert(order order_struct.Order, front bool) {
+	e := &entry{order: order, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[order.OrderUID]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	if front {
+		c.entries[order.OrderUID] = c.lru.PushFront(e)
+	} else {
+		c.entries[order.OrderUID] = c.lru.PushBack(e)
+	}
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+		metrics.MemoryCache("eviction")
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).order.OrderUID)
+}
@@ -0,0 +1,241 @@
+package memcache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/memcache"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCache(t *testing.T) {
+
+	first := generator.GenerateFakeOrder()
+	second := generator.GenerateFakeOrder()
+
+	changed := first
+	changed.Delivery.City = "Changed"
+
+	ctx := context.Background()
+
+	// next tier reads blocked until released
+	blockedRead := func(started, release chan struct{}) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			close(started)
+			<-release
+		}
+	}
+	savedStarted, savedRelease := make(chan struct{}), make(chan struct{})
+	evictedStarted, evictedRelease := make(chan struct{}), make(chan struct{})
+	sharedStarted, sharedRelease := make(chan struct{}), make(chan struct{})
+	var sharedCtx context.Context
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		conf           config.CacheConfig
+		tiered         bool
+		mockCacheSetup func(mockCache *mocksredis.CacheClient)
+		run            func(t *testing.T, c *memcache.Cache)
+		expectedStats  memcache.Stats
+	}{
+		{
+			name: "Standalone hit and miss",
+			conf: config.CacheConfig{Size: 10, TTL: time.Minute},
+			run: func(t *testing.T, c *memcache.Cache) {
+				assert.NoError(t, c.SaveOrder(ctx, first))
+				got, err := c.GetOrder(ctx, first.OrderUID)
+				assert.NoError(t, err)
+				assert.Equal(t, first, got)
+				_, err = c.GetOrder(ctx, second.OrderUID)
+				assert.ErrorIs(t, err, redisclient.ErrCacheMiss)
+			},
+			expectedStats: memcache.Stats{Hits: 1, Misses: 1, Size: 1},
+		},
+		{
+			name: "Least recently used is evicted",
+			conf: config.CacheConfig{Size: 1, TTL: time.Minute},
+			run: func(t *testing.T, c *memcache.Cache) {
+				assert.NoError(t, c.SaveOrder(ctx, first))
+				assert.NoError(t, c.SaveOrder(ctx, second))
+				_, err := c.GetOrder(ctx, first.OrderUID)
+				assert.ErrorIs(t, err, redisclient.ErrCacheMiss)
+			},
+			expectedStats: memcache.Stats{Misses: 1, Evictions: 1, Size: 1},
+		},
+		{
+			name: "Expired order is a miss",
+			conf: config.CacheConfig{Size: 10, TTL: time.Millisecond},
+			run: func(t *testing.T, c *memcache.Cache) {
+				assert.NoError(t, c.SaveOrder(ctx, first))
+				time.Sleep(5 * time.Millisecond)
+				_, err := c.GetOrder(ctx, first.OrderUID)
+				assert.ErrorIs(t, err, redisclient.ErrCacheMiss)
+			},
+			expectedStats: memcache.Stats{Misses: 1},
+		},
+		{
+			name:   "Writes go through to the next tier",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("SaveOrder", ctx, first).Return(nil).Once()
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				assert.NoError(t, c.SaveOrder(ctx, first))
+				_, err := c.GetOrder(ctx, first.OrderUID)
+				assert.NoError(t, err)
+			},
+			expectedStats: memcache.Stats{Hits: 1, Size: 1},
+		},
+		{
+			name:   "Concurrent misses read the next tier once",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", mock.Anything, first.OrderUID).Return(first, nil).
+					After(50 * time.Millisecond).Once()
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				var wg sync.WaitGroup
+				for range 10 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						got, err := c.GetOrder(ctx, first.OrderUID)
+						assert.NoError(t, err)
+						assert.Equal(t, first.OrderUID, got.OrderUID)
+					}()
+				}
+				wg.Wait()
+			},
+			expectedStats: memcache.Stats{Misses: 10, Size: 1},
+		},
+		{
+			name:   "Miss of the next tier is returned",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", mock.Anything, second.OrderUID).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				_, err := c.GetOrder(ctx, second.OrderUID)
+				assert.ErrorIs(t, err, redisclient.ErrCacheMiss)
+			},
+			expectedStats: memcache.Stats{Misses: 1},
+		},
+		{
+			name:   "Order saved during the read is not replaced by the read",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", mock.Anything, first.OrderUID).Return(first, nil).
+					Run(blockedRead(savedStarted, savedRelease)).Once()
+				mockCache.On("SaveOrder", ctx, changed).Return(nil)
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					c.GetOrder(ctx, first.OrderUID)
+				}()
+				<-savedStarted
+				assert.NoError(t, c.SaveOrder(ctx, changed))
+				close(savedRelease)
+				<-done
+
+				got, err := c.GetOrder(ctx, first.OrderUID)
+				assert.NoError(t, err)
+				assert.Equal(t, changed, got)
+			},
+			expectedStats: memcache.Stats{Hits: 1, Misses: 1, Size: 1},
+		},
+		{
+			name:   "Order evicted during the read is read again",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", mock.Anything, first.OrderUID).Return(first, nil).
+					Run(blockedRead(evictedStarted, evictedRelease)).Once()
+				mockCache.On("GetOrder", mock.Anything, first.OrderUID).Return(changed, nil).Once()
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					c.GetOrder(ctx, first.OrderUID)
+				}()
+				<-evictedStarted
+				c.Evict(first.OrderUID)
+				close(evictedRelease)
+				<-done
+
+				got, err := c.GetOrder(ctx, first.OrderUID)
+				assert.NoError(t, err)
+				assert.Equal(t, changed, got)
+			},
+			expectedStats: memcache.Stats{Misses: 2, Size: 1},
+		},
+		{
+			name:   "Shared read outlives the reader that started it",
+			conf:   config.CacheConfig{Size: 10, TTL: time.Minute},
+			tiered: true,
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", mock.Anything, first.OrderUID).Return(first, nil).
+					Run(func(args mock.Arguments) {
+						sharedCtx = args.Get(0).(context.Context)
+						blockedRead(sharedStarted, sharedRelease)(args)
+					}).Once()
+			},
+			run: func(t *testing.T, c *memcache.Cache) {
+				readerCtx, cancel := context.WithCancel(ctx)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					_, err := c.GetOrder(readerCtx, first.OrderUID)
+					assert.ErrorIs(t, err, context.Canceled)
+				}()
+				<-sharedStarted
+				cancel()
+				<-done
+				assert.NoError(t, sharedCtx.Err())
+				_, ok := sharedCtx.Deadline()
+				assert.True(t, ok)
+				close(sharedRelease)
+
+				assert.Eventually(t, func() bool { return c.Stats().Size == 1 }, time.Second, time.Millisecond)
+				got, err := c.GetOrder(ctx, first.OrderUID)
+				assert.NoError(t, err)
+				assert.Equal(t, first, got)
+			},
+			expectedStats: memcache.Stats{Hits: 1, Misses: 1, Size: 1},
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *memcache.Cache
+			if tt.tiered {
+				mockCache := mocksredis.NewCacheClient(t)
+				tt.mockCacheSetup(mockCache)
+				c = memcache.New(tt.conf, mockCache)
+			} else {
+				c = memcache.New(tt.conf, nil)
+			}
+
+			tt.run(t, c)
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStats, c.Stats())
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "l0"
+
+// stages of a consumed message
+const (
+	StageConsumed = "consumed"
+	StageDecoded  = "decoded"
+	StageRejected = "rejected"
+	StageStored   = "stored"
+)
+
+// results of a cache lookup by GetOrder
+const (
+	CacheHit = "hit"
+	// order is not cached and is read from db
+	CacheMiss = "miss"
+	// cache failed and db is used instead
+	CacheFallback = "fallback"
+)
+
+var (
+	messages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_total",
+		Help:      "Kafka messages by partition and processing stage.",
+	}, []string{"topic", "partition", "stage"})
+
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_lag",
+		Help:      "Messages of a partition not handled yet.",
+	}, []string{"topic", "partition"})
+
+	insertOrderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "insert_order_duration_seconds",
+		Help:      "Duration of storing an order in db.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	saveOrderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "save_order_duration_seconds",
+		Help:      "Duration of caching an order.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Order lookups by cache result.",
+	}, []string{"result"})
+
+	memoryCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "memory_cache_total",
+		Help:      "In-process cache lookups and evictions.",
+	}, []string{"result"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	restoreDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_restore_duration_seconds",
+		Help:      "Duration of the last cache restore from db.",
+	})
+
+	restoreOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_restore_orders",
+		Help:      "Orders cached by the last cache restore from db.",
+	})
+
+	cacheInconsistencies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_inconsistent_orders",
+		Help:      "Cached orders disagreeing with db found by the last reconciliation.",
+	}, []string{"kind"})
+)
+
+// Handler serves metrics of the default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func Message(topic string, partition int32, stage string) {
+	messages.WithLabelValues(topic, strconv.Itoa(int(partition)), stage).Inc()
+}
+
+func ConsumerLag(topic string, partition int32, lag int64) {
+	consumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// ResetConsumerLag forgets partitions released on rebalance
+func ResetConsumerLag() {
+	consumerLag.Reset()
+}
+
+func InsertOrder(start time.Time, result string) {
+	insertOrderDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+func SaveOrder(start time.Time, err error) {
+	saveOrderDuration.WithLabelValues(errorResult(err)).Observe(time.Since(start).Seconds())
+}
+
+func CacheLookup(result string) {
+	cacheLookups.WithLabelValues(result).Inc()
+}
+
+// MemoryCache counts "hit", "miss" and "eviction" of the in-process cache
+func MemoryCache(result string) {
+	memoryCache.WithLabelValues(result).Inc()
+}
+
+func HTTPRequest(route, method string, status int, start time.Time) {
+	httpDuration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+func CacheRestore(start time.Time, orders int) {
+	restoreDuration.Set(time.Since(start).Seconds())
+	restoreOrders.Set(float64(orders))
+}
+
+// CacheInconsistencies sets counts of the last reconciliation by kind
+func CacheInconsistencies(counts map[string]int) {
+	for kind, n := range counts {
+		cacheInconsistencies.WithLabelValues(kind).Set(float64(n))
+	}
+}
+
+func errorResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -9,7 +9,7 @@ import (
 	order_struct "github.com/EgorcaA/create_db/internal/order_struct"
 	mock "github.com/stretchr/testify/mock"
 
-	storage "github.com/EgorcaA/create_db/internal/storage"
+	redisclient "github.com/EgorcaA/create_db/internal/redisclient"
 )
 
 // CacheClient is an autogenerated mock type for the CacheClient type
@@ -45,9 +45,32 @@ func (_m *CacheClient) GetOrder(ctx context.Context, orderUID string) (order_str
 	return r0, r1
 }
 
-// RestoreCacheFromDB provides a mock function with given fields: ctx, db
-func (_m *CacheClient) RestoreCacheFromDB(ctx context.Context, log *slog.Logger, db *storage.PostgresDB) {
-	_m.Called(ctx, log, db)
+// RestoreCacheFromDB provides a mock function with given fields: ctx, log, src
+func (_m *CacheClient) RestoreCacheFromDB(ctx context.Context, log *slog.Logger, src redisclient.OrderSource) (int, error) {
+	ret := _m.Called(ctx, log, src)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreCacheFromDB")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *slog.Logger, redisclient.OrderSource) (int, error)); ok {
+		return rf(ctx, log, src)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *slog.Logger, redisclient.OrderSource) int); ok {
+		r0 = rf(ctx, log, src)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *slog.Logger, redisclient.OrderSource) error); ok {
+		r1 = rf(ctx, log, src)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // SaveOrder provides a mock function with given fields: ctx, order
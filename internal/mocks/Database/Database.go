@@ -4,9 +4,14 @@ package mocksdb
 
 import (
 	context "context"
+	iter "iter"
 
 	order_struct "github.com/EgorcaA/create_db/internal/order_struct"
 	mock "github.com/stretchr/testify/mock"
+
+	storage "github.com/EgorcaA/create_db/internal/storage"
+
+	time "time"
 )
 
 // Database is an autogenerated mock type for the Database type
@@ -14,9 +19,9 @@ type Database struct {
 	mock.Mock
 }
 
-// GetAllOrders provides a mock function with given fields:
-func (_m *Database) GetAllOrders() ([]order_struct.Order, error) {
-	ret := _m.Called()
+// GetAllOrders provides a mock function with given fields: ctx
+func (_m *Database) GetAllOrders(ctx context.Context) ([]order_struct.Order, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAllOrders")
@@ -24,19 +29,105 @@ func (_m *Database) GetAllOrders() ([]order_struct.Order, error) {
 
 	var r0 []order_struct.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]order_struct.Order, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context) ([]order_struct.Order, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []order_struct.Order); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]order_struct.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrderByTrackNumber provides a mock function with given fields: ctx, trackNumber
+func (_m *Database) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (order_struct.Order, error) {
+	ret := _m.Called(ctx, trackNumber)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrderByTrackNumber")
+	}
+
+	var r0 order_struct.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (order_struct.Order, error)); ok {
+		return rf(ctx, trackNumber)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) order_struct.Order); ok {
+		r0 = rf(ctx, trackNumber)
+	} else {
+		r0 = ret.Get(0).(order_struct.Order)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, trackNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrderByUID provides a mock function with given fields: ctx, orderUID
+func (_m *Database) GetOrderByUID(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	ret := _m.Called(ctx, orderUID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrderByUID")
+	}
+
+	var r0 order_struct.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (order_struct.Order, error)); ok {
+		return rf(ctx, orderUID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) order_struct.Order); ok {
+		r0 = rf(ctx, orderUID)
+	} else {
+		r0 = ret.Get(0).(order_struct.Order)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, orderUID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrdersByCustomer provides a mock function with given fields: ctx, customerID
+func (_m *Database) GetOrdersByCustomer(ctx context.Context, customerID string) ([]order_struct.Order, error) {
+	ret := _m.Called(ctx, customerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrdersByCustomer")
+	}
+
+	var r0 []order_struct.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]order_struct.Order, error)); ok {
+		return rf(ctx, customerID)
 	}
-	if rf, ok := ret.Get(0).(func() []order_struct.Order); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, string) []order_struct.Order); ok {
+		r0 = rf(ctx, customerID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]order_struct.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, customerID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -45,18 +136,76 @@ func (_m *Database) GetAllOrders() ([]order_struct.Order, error) {
 }
 
 // InsertOrder provides a mock function with given fields: ctx, order
-func (_m *Database) InsertOrder(ctx context.Context, order order_struct.Order) error {
+func (_m *Database) InsertOrder(ctx context.Context, order order_struct.Order) (storage.InsertResult, error) {
 	ret := _m.Called(ctx, order)
 
 	if len(ret) == 0 {
 		panic("no return value specified for InsertOrder")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, order_struct.Order) error); ok {
+	var r0 storage.InsertResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, order_struct.Order) (storage.InsertResult, error)); ok {
+		return rf(ctx, order)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, order_struct.Order) storage.InsertResult); ok {
 		r0 = rf(ctx, order)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(storage.InsertResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, order_struct.Order) error); ok {
+		r1 = rf(ctx, order)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrders provides a mock function with given fields: ctx, filter
+func (_m *Database) ListOrders(ctx context.Context, filter storage.OrderFilter) (storage.OrderPage, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListOrders")
+	}
+
+	var r0 storage.OrderPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.OrderFilter) (storage.OrderPage, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.OrderFilter) storage.OrderPage); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(storage.OrderPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.OrderFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecentOrders provides a mock function with given fields: ctx, since, batchSize
+func (_m *Database) RecentOrders(ctx context.Context, since time.Time, batchSize int) iter.Seq2[order_struct.Order, error] {
+	ret := _m.Called(ctx, since, batchSize)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecentOrders")
+	}
+
+	var r0 iter.Seq2[order_struct.Order, error]
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) iter.Seq2[order_struct.Order, error]); ok {
+		r0 = rf(ctx, since, batchSize)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(iter.Seq2[order_struct.Order, error])
+		}
 	}
 
 	return r0
@@ -0,0 +1,240 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+)
+
+// number of orders read from db at once
+const batchSize = 500
+
+// kinds of inconsistency
+const (
+	// order of the hot window is not cached
+	KindMissing = "missing"
+	// cached order differs from db or can't be decoded
+	KindStale = "stale"
+	// cached order is not in db
+	KindOrphaned = "orphaned"
+	// only some keys of the cached order are left
+	KindHalfWritten = "half_written"
+)
+
+// Report lists order UIDs of every kind of inconsistency found
+type Report struct {
+	Checked     int      `json:"checked"`
+	Missing     []string `json:"missing"`
+	Stale       []string `json:"stale"`
+	Orphaned    []string `json:"orphaned"`
+	HalfWritten []string `json:"half_written"`
+	Repaired    int      `json:"repaired"`
+	Failed      int      `json:"failed"`
+}
+
+// Counts returns number of inconsistent orders by kind
+func (r Report) Counts() map[string]int {
+	return map[string]int{
+		KindMissing:     len(r.Missing),
+		KindStale:       len(r.Stale),
+		KindOrphaned:    len(r.Orphaned),
+		KindHalfWritten: len(r.HalfWritten),
+	}
+}
+
+func (r *Report) add(kind, orderUID string) {
+	switch kind {
+	case KindMissing:
+		r.Missing = append(r.Missing, orderUID)
+	case KindStale:
+		r.Stale = append(r.Stale, orderUID)
+	case KindOrphaned:
+		r.Orphaned = append(r.Orphaned, orderUID)
+	case KindHalfWritten:
+		r.HalfWritten = append(r.HalfWritten, orderUID)
+	}
+}
+
+// Reconciler compares orders cached in redis with db
+type Reconciler struct {
+	log *slog.Logger
+	rdb *redisclient.RedisCache
+	db  storage.Database
+
+	// newest orders created within the window must be cached, at most limit
+	// of them, others may be
+	window time.Duration
+	limit  int
+}
+
+func New(log *slog.Logger, rdb *redisclient.RedisCache, db storage.Database, redis_conf config.RedisConfig) *Reconciler {
+	return &Reconciler{log: log, rdb: rdb, db: db, window: redis_conf.RestoreWindow, limit: redis_conf.RestoreLimit}
+}
+
+// Run walks the hot window of orders in db and every order in the cache keyspace.
+// With repair, missing, stale and half-written orders are cached again from db
+// and orphaned ones are deleted, if they are still inconsistent when db is read again.
+func (rc *Reconciler) Run(ctx context.Context, repair bool) (Report, error) {
+	var report Report
+	start := time.Now()
+
+	cached, err := rc.rdb.CachedOrderUIDs(ctx)
+	if err != nil {
+		return report, fmt.Errorf("error scanning cached orders: %w", err)
+	}
+
+	var since time.Time
+	if rc.window > 0 {
+		since = time.Now().Add(-rc.window)
+	}
+	hot := 0
+	for order, err := range rc.db.RecentOrders(ctx, since, batchSize) {
+		if err != nil {
+			return report, fmt.Errorf("error reading orders: %w", err)
+		}
+		_, isCached := cached[order.OrderUID]
+		delete(cached, order.OrderUID)
+		if !isCached && rc.limit > 0 && hot >= rc.limit {
+			continue
+		}
+		hot++
+		if err := rc.check(ctx, &report, order.OrderUID, &order, isCached, repair); err != nil {
+			return report, err
+		}
+	}
+
+	// cached orders out of the hot window
+	for orderUID := range cached {
+		order, err := rc.db.GetOrderByUID(ctx, orderUID)
+		switch {
+		case errors.Is(err, storage.ErrOrderNotFound):
+			err = rc.check(ctx, &report, orderUID, nil, true, repair)
+		case err != nil:
+			return report, fmt.Errorf("error reading order %s: %w", orderUID, err)
+		default:
+			err = rc.check(ctx, &report, orderUID, &order, true, repair)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	metrics.CacheInconsistencies(report.Counts())
+	rc.log.Info("Cache reconciliation finished",
+		slog.Int("checked", report.Checked),
+		slog.Any("inconsistent", report.Counts()),
+		slog.Int("repaired", report.Repaired),
+		slog.Int("failed", report.Failed),
+		slog.Duration("took", time.Since(start)))
+	return report, nil
+}
+
+// check compares the cached order with the db one, which is nil for an order not in db
+func (rc *Reconciler) check(ctx context.Context, report *Report, orderUID string, order *order_struct.Order,
+	isCached, repair bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	report.Checked++
+
+	kind, err := rc.inspect(ctx, orderUID, order, isCached)
+	if err != nil || kind == "" {
+		return err
+	}
+	report.add(kind, orderUID)
+	rc.log.Warn("Inconsistent cached order", slog.String("kind", kind), slog.String("OrderUID", orderUID))
+	if !repair {
+		return nil
+	}
+
+	// order may have been written since its batch was read,
+	// it is compared again with the current db state before the cache is touched
+	fresh, err := rc.db.GetOrderByUID(ctx, orderUID)
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		order = nil
+	case err != nil:
+		report.Failed++
+		rc.log.Error(fmt.Sprintf("Error reading order %s to repair: %v", orderUID, err))
+		return nil
+	default:
+		order = &fresh
+	}
+	if kind, err = rc.inspect(ctx, orderUID, order, true); err != nil || kind == "" {
+		return err
+	}
+
+	if order != nil {
+		err = rc.rdb.SaveOrder(ctx, *order)
+	} else {
+		err = rc.rdb.DeleteOrder(ctx, orderUID)
+	}
+	if err != nil {
+		report.Failed++
+		rc.log.Error(fmt.Sprintf("Error repairing cached order %s: %v", orderUID, err))
+		return nil
+	}
+	report.Repaired++
+	return nil
+}
+
+// inspect returns the kind of inconsistency of the cached order, empty when it matches db
+func (rc *Reconciler) inspect(ctx context.Context, orderUID string, order *order_struct.Order,
+	isCached bool) (string, error) {
+	if !isCached {
+		return KindMissing, nil
+	}
+	cachedOrder, err := rc.rdb.CachedOrder(ctx, orderUID)
+	switch {
+	case errors.Is(err, redisclient.ErrHalfWritten):
+		return KindHalfWritten, nil
+	case order == nil:
+		return KindOrphaned, nil
+	case errors.Is(err, redisclient.ErrCacheMiss):
+		// expired between the scan and the read, or unreadable
+		return KindStale, nil
+	case err != nil:
+		return "", fmt.Errorf("error reading cached order %s: %w", orderUID, err)
+	case !sameOrder(cachedOrder, *order):
+		return KindStale, nil
+	}
+	return "", nil
+}
+
+// sameOrder ignores what the cache doesn't keep: precision of dates and nil vs empty items
+func sameOrder(a, b order_struct.Order) bool {
+	normalize := func(o order_struct.Order) order_struct.Order {
+		o.DateCreated = o.DateCreated.Truncate(time.Second).UTC()
+		if len(o.Items) == 0 {
+			o.Items = nil
+		}
+		return o
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// RunEvery runs the reconciliation with the given interval until ctx is done.
+// It only reports inconsistencies, they are repaired by the reconcile command.
+func (rc *Reconciler) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := rc.Run(ctx, false); err != nil && ctx.Err() == nil {
+				rc.log.Error(fmt.Sprintf("Cache reconciliation failed: %v", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package reconcile_test
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/reconcile"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func orderSeq(orders ...order_struct.Order) iter.Seq2[order_struct.Order, error] {
+	return func(yield func(order_struct.Order, error) bool) {
+		for _, order := range orders {
+			if !yield(order, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	changed := order
+	changed.Delivery.City = "Changed"
+	orphan := generator.GenerateFakeOrder()
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache)
+		repair bool
+		// db state of order read again before the repair, order when empty
+		fresh          order_struct.Order
+		expectedReport reconcile.Report
+		// report of the check after the repair
+		expectedAfter reconcile.Report
+	}{
+		{
+			name: "Consistent",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+			},
+			expectedReport: reconcile.Report{Checked: 1},
+		},
+		{
+			name:           "Missing",
+			setup:          func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {},
+			expectedReport: reconcile.Report{Checked: 1, Missing: []string{order.OrderUID}},
+		},
+		{
+			name: "Stale",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, changed))
+			},
+			expectedReport: reconcile.Report{Checked: 1, Stale: []string{order.OrderUID}},
+		},
+		{
+			name: "Orphaned",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				require.NoError(t, rdb.SaveOrder(ctx, orphan))
+			},
+			expectedReport: reconcile.Report{Checked: 2, Orphaned: []string{orphan.OrderUID}},
+		},
+		{
+			name: "Half-written",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				s.Del("order:" + order.OrderUID + ":payment")
+			},
+			expectedReport: reconcile.Report{Checked: 1, HalfWritten: []string{order.OrderUID}},
+		},
+		{
+			name: "Orphaned half-written",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				require.NoError(t, rdb.SaveOrder(ctx, orphan))
+				s.Del("order:" + orphan.OrderUID)
+			},
+			expectedReport: reconcile.Report{Checked: 2, HalfWritten: []string{orphan.OrderUID}},
+		},
+		{
+			name: "Repair",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, changed))
+				require.NoError(t, rdb.SaveOrder(ctx, orphan))
+			},
+			repair: true,
+			expectedReport: reconcile.Report{Checked: 2, Stale: []string{order.OrderUID},
+				Orphaned: []string{orphan.OrderUID}, Repaired: 2},
+			expectedAfter: reconcile.Report{Checked: 1},
+		},
+		{
+			name: "Order written during the check is not repaired",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, changed))
+			},
+			repair:         true,
+			fresh:          changed,
+			expectedReport: reconcile.Report{Checked: 1, Stale: []string{order.OrderUID}},
+			// the batch snapshot is still the old one, the cache keeps the new order
+			expectedAfter: reconcile.Report{Checked: 1, Stale: []string{order.OrderUID}},
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			redis_conf := config.RedisConfig{Host: s.Host(), Port: s.Port(), RestoreWindow: time.Hour}
+			rdb, err := redisclient.InitRedis(redis_conf, slogdiscard.NewDiscardLogger())
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+			tt.setup(t, s, rdb)
+
+			db := mocksdb.NewDatabase(t)
+			db.On("RecentOrders", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).
+				Return(orderSeq(order))
+			db.On("GetOrderByUID", mock.Anything, orphan.OrderUID).
+				Return(order_struct.Order{}, storage.ErrOrderNotFound).Maybe()
+			fresh := tt.fresh
+			if fresh.OrderUID == "" {
+				fresh = order
+			}
+			db.On("GetOrderByUID", mock.Anything, order.OrderUID).Return(fresh, nil).Maybe()
+
+			rc := reconcile.New(slogdiscard.NewDiscardLogger(), rdb, db, redis_conf)
+			report, err := rc.Run(ctx, tt.repair)
+
+			// Assert expectations
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedReport, report)
+			if tt.repair {
+				report, err := rc.Run(ctx, false)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedAfter, report)
+			}
+		})
+	}
+}
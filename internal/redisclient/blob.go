@@ -0,0 +1,192 @@
+package redisclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// cache layouts
+const (
+	LayoutHash = "hash"
+	LayoutBlob = "blob"
+)
+
+// blob encodings
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// Blob is [schema version][codec][payload]. A blob of another schema version
+// is a cache miss, the order is read from db and cached again in the current one.
+const blobVersion byte = 1
+
+// codec byte, the high bit marks gzipped payload
+const (
+	codecJSON    byte = 1
+	codecMsgpack byte = 2
+	codecGzip    byte = 0x80
+)
+
+func blobKey(orderUID string) string {
+	return "orderblob:" + orderUID
+}
+
+// keys of the order in hash layout
+func hashKeys(orderUID string) []string {
+	orderKey := "order:" + orderUID
+	return []string{orderKey, orderKey + ":delivery", orderKey + ":payment", orderKey + ":items"}
+}
+
+func encodeOrder(order order_struct.Order, encoding string, compress bool) ([]byte, error) {
+	var buf bytes.Buffer
+	codec := codecJSON
+	if encoding == EncodingMsgpack {
+		codec = codecMsgpack
+	}
+	if compress {
+		codec |= codecGzip
+	}
+	buf.Write([]byte{blobVersion, codec})
+
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+
+	var err error
+	if codec&^codecGzip == codecMsgpack {
+		enc := msgpack.NewEncoder(w)
+		// field names stay the same as in JSON
+		enc.SetCustomStructTag("json")
+		err = enc.Encode(order)
+	} else {
+		err = json.NewEncoder(w).Encode(order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeOrder reads the order from a blob. A blob that can't be decoded is
+// a cache miss, so the order is read from db and cached again.
+func decodeOrder(data []byte) (order_struct.Order, error) {
+	var order order_struct.Order
+	if len(data) < 2 {
+		return order, fmt.Errorf("%w: cached blob is too short", ErrCacheMiss)
+	}
+	if data[0] != blobVersion {
+		return order, fmt.Errorf("%w: blob schema version %d", ErrCacheMiss, data[0])
+	}
+
+	codec := data[1]
+	var r io.Reader = bytes.NewReader(data[2:])
+	if codec&codecGzip != 0 {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return order, fmt.Errorf("%w: error decompressing cached blob: %v", ErrCacheMiss, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	switch codec &^ codecGzip {
+	case codecJSON:
+		if err := json.NewDecoder(r).Decode(&order); err != nil {
+			return order, fmt.Errorf("%w: error decoding cached blob: %v", ErrCacheMiss, err)
+		}
+	case codecMsgpack:
+		dec := msgpack.NewDecoder(r)
+		dec.SetCustomStructTag("json")
+		if err := dec.Decode(&order); err != nil {
+			return order, fmt.Errorf("%w: error decoding cached blob: %v", ErrCacheMiss, err)
+		}
+	default:
+		return order, fmt.Errorf("%w: blob codec %d", ErrCacheMiss, codec)
+	}
+	return order, nil
+}
+
+// saveBlob writes the order as one value, hash layout keys of it are removed
+func (rdb *RedisCache) saveBlob(ctx context.Context, order order_struct.Order) error {
+	data, err := encodeOrder(order, rdb.encoding, rdb.compress)
+	if err != nil {
+		return err
+	}
+	return rdb.writeOrder(ctx, order.OrderUID, func(pipe redis.Pipeliner, previousCustomerID string) {
+		pipe.Set(ctx, blobKey(order.OrderUID), data, rdb.ttl)
+		pipe.Del(ctx, hashKeys(order.OrderUID)...)
+		rdb.indexOrder(ctx, pipe, order, previousCustomerID)
+		rdb.publishInvalidation(ctx, pipe, order.OrderUID, OpUpsert)
+	})
+}
+
+// getBlob reads the order blob. An order still cached in hash layout
+// is returned as well and rewritten as a blob.
+func (rdb *RedisCache) getBlob(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	data, err := rdb.Conn.Get(ctx, blobKey(orderUID)).Bytes()
+	if err == nil {
+		return decodeOrder(data)
+	}
+	if !errors.Is(err, redis.Nil) {
+		return order_struct.Order{}, err
+	}
+
+	order, err := rdb.getHash(ctx, orderUID)
+	if err != nil {
+		return order, err
+	}
+	// migration is best effort, the order is already read
+	rdb.saveBlob(ctx, order)
+	return order, nil
+}
+
+// MigrateLayout rewrites every order cached in hash layout to the blob one
+// and returns the number of migrated orders
+func (rdb *RedisCache) MigrateLayout(ctx context.Context, log *slog.Logger) (int, error) {
+	if rdb.layout != LayoutBlob {
+		return 0, fmt.Errorf("cache layout is %q, set it to %q to migrate", rdb.layout, LayoutBlob)
+	}
+
+	migrated := 0
+	iter := rdb.Conn.ScanType(ctx, 0, "order:*", restoreBatchSize, "hash").Iterator()
+	for iter.Next(ctx) {
+		// skip :delivery and :payment parts
+		orderUID, ok := strings.CutPrefix(iter.Val(), "order:")
+		if !ok || strings.Contains(orderUID, ":") {
+			continue
+		}
+		order, err := rdb.getHash(ctx, orderUID)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Skipping cached order %s: %v", orderUID, err))
+			continue
+		}
+		if err := rdb.saveBlob(ctx, order); err != nil {
+			return migrated, fmt.Errorf("error migrating cached order %s: %w", orderUID, err)
+		}
+		migrated++
+	}
+	if err := iter.Err(); err != nil {
+		return migrated, err
+	}
+	return migrated, nil
+}
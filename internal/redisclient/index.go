@@ -0,0 +1,96 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/redis/go-redis/v9"
+)
+
+// attempts of a write whose order was changed by another writer meanwhile
+const writeAttempts = 3
+
+// customerOrdersKey is the index of orders cached for the customer, a sorted set
+// scored by the unix time in milliseconds the cached order expires at
+func customerOrdersKey(customerID string) string {
+	return "customerorders:" + customerID
+}
+
+// legacyCustomerOrdersKey is the plain set index of earlier versions, it never
+// dropped members of expired orders and is removed once the customer is written
+func legacyCustomerOrdersKey(customerID string) string {
+	return "customer:" + customerID + ":orders"
+}
+
+// writeOrder queues the changes of the order in one MULTI/EXEC transaction.
+// The customer the order is cached for is read under WATCH and passed to write,
+// so the transaction fails and is retried if the order changed after the read.
+func (rdb *RedisCache) writeOrder(ctx context.Context, orderUID string,
+	write func(pipe redis.Pipeliner, previousCustomerID string)) error {
+	for range writeAttempts {
+		err := rdb.Conn.Watch(ctx, func(tx *redis.Tx) error {
+			previousCustomerID, err := cachedCustomerID(ctx, tx, orderUID)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				write(pipe, previousCustomerID)
+				return nil
+			})
+			return err
+		}, "order:"+orderUID, blobKey(orderUID))
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
+// indexOrder queues the index update of the written order. The order leaves
+// the index of the customer it was cached for before, and members whose
+// orders expired are dropped from the index it joins.
+func (rdb *RedisCache) indexOrder(ctx context.Context, pipe redis.Pipeliner,
+	order order_struct.Order, previousCustomerID string) {
+	if previousCustomerID != "" && previousCustomerID != order.CustomerID {
+		pipe.ZRem(ctx, customerOrdersKey(previousCustomerID), order.OrderUID)
+	}
+
+	key := customerOrdersKey(order.CustomerID)
+	now := time.Now()
+	expires := math.Inf(1)
+	if rdb.ttl > 0 {
+		expires = float64(now.Add(rdb.ttl).UnixMilli())
+	}
+	pipe.ZAdd(ctx, key, redis.Z{Score: expires, Member: order.OrderUID})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	pipe.Del(ctx, legacyCustomerOrdersKey(order.CustomerID))
+	// no member outlives the order written last
+	if rdb.ttl > 0 {
+		pipe.Expire(ctx, key, rdb.ttl)
+	}
+}
+
+// cachedCustomerID returns the customer of the order in whatever layout it is cached,
+// empty when the order is not cached or its blob can't be decoded
+func cachedCustomerID(ctx context.Context, c redis.Cmdable, orderUID string) (string, error) {
+	customerID, err := c.HGet(ctx, "order:"+orderUID, "CustomerID").Result()
+	if !errors.Is(err, redis.Nil) {
+		return customerID, err
+	}
+	data, err := c.Get(ctx, blobKey(orderUID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	order, err := decodeOrder(data)
+	if err != nil {
+		return "", nil
+	}
+	return order.CustomerID, nil
+}
@@ -0,0 +1,111 @@
+package redisclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// channel of order invalidation events shared by all instances
+const invalidationChannel = "orders:invalidate"
+
+// delay before subscribing again after the subscription dropped
+const resubscribeDelay = time.Second
+
+// invalidation ops
+const (
+	OpUpsert = "upsert"
+	OpDelete = "delete"
+)
+
+// Invalidation tells other instances their local copy of the order is stale
+type Invalidation struct {
+	OrderUID string `json:"order_uid"`
+	Op       string `json:"op"`
+	// instance that changed the order, it keeps its own local copy
+	Source string `json:"source"`
+}
+
+// newInstanceID identifies the process among instances sharing redis
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// publishInvalidation queues the event in the write transaction,
+// so it is sent only if the order is written
+func (rdb *RedisCache) publishInvalidation(ctx context.Context, pipe redis.Pipeliner, orderUID, op string) {
+	data, _ := json.Marshal(Invalidation{OrderUID: orderUID, Op: op, Source: rdb.instanceID})
+	pipe.Publish(ctx, invalidationChannel, data)
+}
+
+// DeleteOrder removes the order from cache in every layout and from the index of its customer
+func (rdb *RedisCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	return rdb.writeOrder(ctx, orderUID, func(pipe redis.Pipeliner, previousCustomerID string) {
+		pipe.Del(ctx, append(hashKeys(orderUID), blobKey(orderUID))...)
+		if previousCustomerID != "" {
+			pipe.ZRem(ctx, customerOrdersKey(previousCustomerID), orderUID)
+		}
+		rdb.publishInvalidation(ctx, pipe, orderUID, OpDelete)
+	})
+}
+
+// SubscribeInvalidations calls evict for orders changed by other instances until ctx is done.
+// Events sent while the subscription is down are lost, so flush is called
+// when it drops and again once it is restored.
+func (rdb *RedisCache) SubscribeInvalidations(ctx context.Context, log *slog.Logger,
+	evict func(orderUID string), flush func()) {
+	ps := rdb.Conn.Subscribe(ctx, invalidationChannel)
+	defer ps.Close()
+
+	dropped := false
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !dropped {
+				log.Warn(fmt.Sprintf("Invalidation subscription dropped, flushing local cache: %v", err))
+				dropped = true
+				flush()
+			}
+			// next Receive reconnects and subscribes again
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if dropped {
+				log.Info("Invalidation subscription restored, flushing local cache")
+				dropped = false
+				flush()
+			}
+		case *redis.Message:
+			var event Invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &event); err != nil {
+				log.Warn(fmt.Sprintf("Bad invalidation event: %v", err))
+				continue
+			}
+			if event.Source == rdb.instanceID {
+				continue
+			}
+			log.Debug("Order invalidated", slog.String("OrderUID", event.OrderUID), slog.String("op", event.Op))
+			evict(event.OrderUID)
+		}
+	}
+}
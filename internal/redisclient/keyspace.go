@@ -0,0 +1,43 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/redis/go-redis/v9"
+)
+
+// CachedOrderUIDs scans the keyspace for orders cached in either layout,
+// an order having any of its keys is listed
+func (rdb *RedisCache) CachedOrderUIDs(ctx context.Context) (map[string]struct{}, error) {
+	uids := make(map[string]struct{})
+	for _, prefix := range []string{"order:", "orderblob:"} {
+		iter := rdb.Conn.Scan(ctx, 0, prefix+"*", restoreBatchSize).Iterator()
+		for iter.Next(ctx) {
+			orderUID := strings.TrimPrefix(iter.Val(), prefix)
+			// order:X:delivery, order:X:payment and order:X:items
+			orderUID, _, _ = strings.Cut(orderUID, ":")
+			uids[orderUID] = struct{}{}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return uids, nil
+}
+
+// CachedOrder reads the order in whatever layout it is cached, unlike GetOrder
+// it never rewrites it. Returns ErrHalfWritten for an incomplete hash layout order
+// and ErrCacheMiss for a blob that can't be decoded.
+func (rdb *RedisCache) CachedOrder(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	data, err := rdb.Conn.Get(ctx, blobKey(orderUID)).Bytes()
+	if err == nil {
+		return decodeOrder(data)
+	}
+	if !errors.Is(err, redis.Nil) {
+		return order_struct.Order{}, err
+	}
+	return rdb.getHash(ctx, orderUID)
+}
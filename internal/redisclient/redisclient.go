@@ -3,6 +3,7 @@ package redisclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -11,9 +12,12 @@ import (
 
 	"github.com/EgorcaA/create_db/internal/config"
 	_ "github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/metrics"
 	"github.com/EgorcaA/create_db/internal/order_struct"
-	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/EgorcaA/create_db/internal/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // creates redis client
@@ -24,49 +28,97 @@ func InitRedis(redis_conf config.RedisConfig, log *slog.Logger) (rdb *RedisCache
 		DB:   0,                                                  // Default DB
 	})
 
+	// unreachable redis is reported by readiness, the client reconnects by itself
 	_, err = rdbb.Ping(context.Background()).Result()
 	if err != nil {
 		log.Error(fmt.Sprintf("Failed to connect to Redis: %v", err))
+	} else {
+		log.Info("Connected to Redis")
 	}
-
-	log.Info("Connected to Redis")
-	return &RedisCache{Conn: rdbb}, nil
+	return &RedisCache{
+		Conn:          rdbb,
+		ttl:           redis_conf.OrderTTL,
+		restoreWindow: redis_conf.RestoreWindow,
+		restoreLimit:  redis_conf.RestoreLimit,
+		layout:        redis_conf.Layout,
+		encoding:      redis_conf.Encoding,
+		compress:      redis_conf.Compress,
+		instanceID:    newInstanceID(),
+	}, err
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.49.1 --name=CacheClient --outpkg=mocks --dir=.
 type CacheClient interface {
-	RestoreCacheFromDB(ctx context.Context, log *slog.Logger, db *storage.PostgresDB)
+	RestoreCacheFromDB(ctx context.Context, log *slog.Logger, src OrderSource) (int, error)
 	SaveOrder(ctx context.Context, order order_struct.Order) error
 	GetOrder(ctx context.Context, orderUID string) (order_struct.Order, error)
 }
 
+// ErrCacheMiss is returned by GetOrder when the order is not cached
+var ErrCacheMiss = errors.New("order is not cached")
+
+// ErrHalfWritten is a cache miss of an order having only some of its keys
+var ErrHalfWritten = fmt.Errorf("%w: order is half-written", ErrCacheMiss)
+
 type RedisCache struct {
 	Conn *redis.Client
+
+	// expiry of every key of a cached order, 0 means none
+	ttl time.Duration
+	// hot window of the startup restore
+	restoreWindow time.Duration
+	restoreLimit  int
+
+	// cache layout and blob format
+	layout   string
+	encoding string
+	compress bool
+
+	// source of invalidation events sent by this instance
+	instanceID string
 }
 
-func (rdb *RedisCache) RestoreCacheFromDB(ctx context.Context, log *slog.Logger, db *storage.PostgresDB) {
-	// Fetch all orders from the database
-	orders, err := db.GetAllOrders()
-	if err != nil {
-		log.Error(fmt.Sprintf("Error recovering cache from db: %v", err))
-		return
-	}
+// Ping checks redis is reachable
+func (rdb *RedisCache) Ping(ctx context.Context) error {
+	return rdb.Conn.Ping(ctx).Err()
+}
 
-	for _, order := range orders {
-		cacheKey := "order:" + order.OrderUID
-		err := rdb.SaveOrder(ctx, order)
-		if err != nil {
-			log.Error(fmt.Sprintf("Error caching order %s: %v", order.OrderUID, err))
-		} else {
-			log.Debug(fmt.Sprintf("Order recovered in cache: %s", cacheKey))
-		}
+// RestoreCacheFromDB caches the hot window of orders from src
+func (rdb *RedisCache) RestoreCacheFromDB(ctx context.Context, log *slog.Logger, src OrderSource) (int, error) {
+	var since time.Time
+	if rdb.restoreWindow > 0 {
+		since = time.Now().Add(-rdb.restoreWindow)
 	}
-	log.Info("Cache successfully recovered from db")
+	return RestoreOrders(ctx, log, src, since, rdb.restoreLimit, rdb.SaveOrder)
 }
 
 func (rdb *RedisCache) SaveOrder(ctx context.Context, order order_struct.Order) error {
-	// Save general order details
+	ctx, span := tracing.Tracer().Start(ctx, "SaveOrder",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("order.uid", order.OrderUID)))
+	defer span.End()
+
+	start := time.Now()
+	var err error
+	if rdb.layout == LayoutBlob {
+		err = rdb.saveBlob(ctx, order)
+	} else {
+		err = rdb.saveHash(ctx, order)
+	}
+	metrics.SaveOrder(start, err)
+	tracing.RecordError(span, err)
+	return err
+}
+
+// saveHash writes every part of the order in a single MULTI/EXEC transaction,
+// so a reader never sees a half-written order and re-saving replaces the items
+func (rdb *RedisCache) saveHash(ctx context.Context, order order_struct.Order) error {
 	orderKey := "order:" + order.OrderUID
+	deliveryKey := orderKey + ":delivery"
+	paymentKey := orderKey + ":payment"
+	itemsKey := orderKey + ":items"
+
+	// general order details
 	orderData := map[string]interface{}{
 		"OrderUID":          order.OrderUID,
 		"TrackNumber":       order.TrackNumber,
@@ -80,12 +132,6 @@ func (rdb *RedisCache) SaveOrder(ctx context.Context, order order_struct.Order)
 		"DateCreated":       order.DateCreated.Unix(),
 		"OOFShard":          order.OOFShard,
 	}
-	if err := rdb.Conn.HSet(ctx, orderKey, orderData).Err(); err != nil {
-		return err
-	}
-
-	// Save delivery details
-	deliveryKey := orderKey + ":delivery"
 	deliveryData := map[string]interface{}{
 		"Name":    order.Delivery.Name,
 		"Phone":   order.Delivery.Phone,
@@ -95,12 +141,6 @@ func (rdb *RedisCache) SaveOrder(ctx context.Context, order order_struct.Order)
 		"Region":  order.Delivery.Region,
 		"Email":   order.Delivery.Email,
 	}
-	if err := rdb.Conn.HSet(ctx, deliveryKey, deliveryData).Err(); err != nil {
-		return err
-	}
-
-	// Save payment details
-	paymentKey := orderKey + ":payment"
 	paymentData := map[string]interface{}{
 		"Transaction":  order.Payment.Transaction,
 		"RequestID":    order.Payment.RequestID,
@@ -113,43 +153,73 @@ func (rdb *RedisCache) SaveOrder(ctx context.Context, order order_struct.Order)
 		"GoodsTotal":   order.Payment.GoodsTotal,
 		"CustomFee":    order.Payment.CustomFee,
 	}
-	if err := rdb.Conn.HSet(ctx, paymentKey, paymentData).Err(); err != nil {
-		return err
-	}
-
-	// Save items
-	itemsKey := orderKey + ":items"
+	items := make([]interface{}, 0, len(order.Items))
 	for _, item := range order.Items {
 		itemJSON, err := json.Marshal(item)
 		if err != nil {
 			return err
 		}
-		if err := rdb.Conn.RPush(ctx, itemsKey, itemJSON).Err(); err != nil {
-			return err
-		}
+		items = append(items, itemJSON)
 	}
 
-	// Add order to customer's order list
-	customerOrdersKey := "customer:" + order.CustomerID + ":orders"
-	if err := rdb.Conn.SAdd(ctx, customerOrdersKey, order.OrderUID).Err(); err != nil {
-		return err
-	}
+	return rdb.writeOrder(ctx, order.OrderUID, func(pipe redis.Pipeliner, previousCustomerID string) {
+		// previous version of the order is replaced, not merged
+		pipe.Del(ctx, orderKey, deliveryKey, paymentKey, itemsKey)
+		pipe.HSet(ctx, orderKey, orderData)
+		pipe.HSet(ctx, deliveryKey, deliveryData)
+		pipe.HSet(ctx, paymentKey, paymentData)
+		if len(items) > 0 {
+			pipe.RPush(ctx, itemsKey, items...)
+		}
+		rdb.indexOrder(ctx, pipe, order, previousCustomerID)
+		rdb.publishInvalidation(ctx, pipe, order.OrderUID, OpUpsert)
 
-	return nil
+		// parts of the order expire together
+		if rdb.ttl > 0 {
+			for _, key := range []string{orderKey, deliveryKey, paymentKey, itemsKey} {
+				pipe.Expire(ctx, key, rdb.ttl)
+			}
+		}
+	})
 }
 
 func (rdb *RedisCache) GetOrder(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	if rdb.layout == LayoutBlob {
+		return rdb.getBlob(ctx, orderUID)
+	}
+	return rdb.getHash(ctx, orderUID)
+}
+
+// getHash reads all parts of the order in one pipelined round trip
+func (rdb *RedisCache) getHash(ctx context.Context, orderUID string) (order_struct.Order, error) {
 	order := order_struct.Order{}
 
-	// Retrieve general order details
 	orderKey := "order:" + orderUID
-	orderData, err := rdb.Conn.HGetAll(ctx, orderKey).Result()
-	if err != nil || orderData["OrderUID"] == "" {
-		// log.Printf("didnt find")
+	var (
+		orderCmd    *redis.MapStringStringCmd
+		deliveryCmd *redis.MapStringStringCmd
+		paymentCmd  *redis.MapStringStringCmd
+		itemsCmd    *redis.StringSliceCmd
+	)
+	_, err := rdb.Conn.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		orderCmd = pipe.HGetAll(ctx, orderKey)
+		deliveryCmd = pipe.HGetAll(ctx, orderKey+":delivery")
+		paymentCmd = pipe.HGetAll(ctx, orderKey+":payment")
+		itemsCmd = pipe.LRange(ctx, orderKey+":items", 0, -1)
+		return nil
+	})
+	if err != nil {
 		return order, err
 	}
-	// log.Printf(orderData["OrderUID"])
-	// log.Printf("found")
+
+	// general order details
+	orderData := orderCmd.Val()
+	if orderData["OrderUID"] == "" {
+		if len(deliveryCmd.Val()) > 0 || len(paymentCmd.Val()) > 0 || len(itemsCmd.Val()) > 0 {
+			return order, ErrHalfWritten
+		}
+		return order, ErrCacheMiss
+	}
 	order.OrderUID = orderData["OrderUID"]
 	order.TrackNumber = orderData["TrackNumber"]
 	order.Entry = orderData["Entry"]
@@ -163,11 +233,11 @@ func (rdb *RedisCache) GetOrder(ctx context.Context, orderUID string) (order_str
 	order.DateCreated = time.Unix(dateCreated, 0)
 	order.OOFShard = orderData["OOFShard"]
 
-	// Retrieve delivery details
-	deliveryKey := orderKey + ":delivery"
-	deliveryData, err := rdb.Conn.HGetAll(ctx, deliveryKey).Result()
-	if err != nil {
-		return order, err
+	// delivery details
+	deliveryData := deliveryCmd.Val()
+	if len(deliveryData) == 0 {
+		// written by an older non-atomic version and partly expired
+		return order, ErrHalfWritten
 	}
 	order.Delivery = order_struct.Delivery{
 		Name:    deliveryData["Name"],
@@ -179,13 +249,11 @@ func (rdb *RedisCache) GetOrder(ctx context.Context, orderUID string) (order_str
 		Email:   deliveryData["Email"],
 	}
 
-	// Retrieve payment details
-	paymentKey := orderKey + ":payment"
-	paymentData, err := rdb.Conn.HGetAll(ctx, paymentKey).Result()
-	if err != nil {
-		return order, err
+	// payment details
+	paymentData := paymentCmd.Val()
+	if len(paymentData) == 0 {
+		return order, ErrHalfWritten
 	}
-
 	tmp_Amount, _ := strconv.Atoi(paymentData["Amount"])
 	tmp_PaymentDT, _ := strconv.ParseInt(paymentData["PaymentDT"], 10, 64)
 	tmp_DeliveryCost, _ := strconv.Atoi(paymentData["DeliveryCost"])
@@ -204,13 +272,8 @@ func (rdb *RedisCache) GetOrder(ctx context.Context, orderUID string) (order_str
 		GoodsTotal:   tmp_GoodsTotal,
 		CustomFee:    tmp_CustomFee}
 
-	// Retrieve items
-	itemsKey := orderKey + ":items"
-	itemsData, err := rdb.Conn.LRange(ctx, itemsKey, 0, -1).Result()
-	if err != nil {
-		return order, err
-	}
-	for _, itemJSON := range itemsData {
+	// items
+	for _, itemJSON := range itemsCmd.Val() {
 		var item order_struct.Item
 		if err := json.Unmarshal([]byte(itemJSON), &item); err != nil {
 			return order, err
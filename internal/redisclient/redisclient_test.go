@@ -0,0 +1,377 @@
+package redisclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	// cache keeps dates with second precision
+	order.DateCreated = order.DateCreated.Truncate(time.Second)
+	orderKey := "order:" + order.OrderUID
+
+	changed := order
+	changed.Items = order.Items[:1]
+	changed.Delivery.City = "Changed"
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name          string
+		setup         func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache)
+		expectedOrder order_struct.Order
+		expectedErr   error
+	}{
+		{
+			name: "Saved order is read back",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+			},
+			expectedOrder: order,
+		},
+		{
+			name: "Re-saving replaces items",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				require.NoError(t, rdb.SaveOrder(ctx, changed))
+			},
+			expectedOrder: changed,
+		},
+		{
+			name:        "Not cached",
+			setup:       func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+		{
+			name: "Half-written order is a miss",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				s.Del(orderKey + ":payment")
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+		{
+			name: "Expired order is a miss",
+			setup: func(t *testing.T, s *miniredis.Miniredis, rdb *redisclient.RedisCache) {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+				for _, key := range []string{orderKey, orderKey + ":delivery", orderKey + ":payment",
+					orderKey + ":items", "customerorders:" + order.CustomerID} {
+					assert.Equal(t, time.Hour, s.TTL(key), key)
+				}
+				s.FastForward(time.Hour)
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			rdb, err := redisclient.InitRedis(config.RedisConfig{
+				Host:     s.Host(),
+				Port:     s.Port(),
+				OrderTTL: time.Hour,
+			}, slogdiscard.NewDiscardLogger())
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+
+			tt.setup(t, s, rdb)
+			got, err := rdb.GetOrder(ctx, order.OrderUID)
+
+			// Assert expectations
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.expectedOrder.Items, got.Items)
+				assert.Equal(t, tt.expectedOrder.Delivery, got.Delivery)
+				assert.Equal(t, tt.expectedOrder.Payment, got.Payment)
+				assert.True(t, tt.expectedOrder.DateCreated.Equal(got.DateCreated))
+			}
+		})
+	}
+}
+
+func TestBlobCache(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	order.DateCreated = time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	blobKey := "orderblob:" + order.OrderUID
+
+	ctx := context.Background()
+	logger := slogdiscard.NewDiscardLogger()
+
+	// Define test cases
+	tests := []struct {
+		name        string
+		conf        config.RedisConfig
+		setup       func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache)
+		save        bool
+		expectedErr error
+	}{
+		{
+			name: "JSON",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob, Encoding: redisclient.EncodingJSON},
+			save: true,
+		},
+		{
+			name: "Compressed MessagePack",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob, Encoding: redisclient.EncodingMsgpack, Compress: true},
+			save: true,
+		},
+		{
+			name: "Order in hash layout is migrated on read",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob},
+			setup: func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache) {
+				require.NoError(t, hash.SaveOrder(ctx, order))
+			},
+		},
+		{
+			name: "Unknown schema version is a miss",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob},
+			setup: func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache) {
+				s.Set(blobKey, "\x09\x01{}")
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+		{
+			name: "Truncated blob is a miss",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob},
+			setup: func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache) {
+				s.Set(blobKey, "\x01")
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+		{
+			name: "Corrupt payload is a miss",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob},
+			setup: func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache) {
+				s.Set(blobKey, "\x01\x01{\"order_uid\":")
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+		{
+			name: "Corrupt compressed payload is a miss",
+			conf: config.RedisConfig{Layout: redisclient.LayoutBlob},
+			setup: func(t *testing.T, s *miniredis.Miniredis, hash *redisclient.RedisCache) {
+				s.Set(blobKey, "\x01\x82not gzip")
+			},
+			expectedErr: redisclient.ErrCacheMiss,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			tt.conf.Host, tt.conf.Port = s.Host(), s.Port()
+			rdb, err := redisclient.InitRedis(tt.conf, logger)
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+
+			if tt.setup != nil {
+				hash, err := redisclient.InitRedis(config.RedisConfig{Host: s.Host(), Port: s.Port(), Layout: redisclient.LayoutHash}, logger)
+				require.NoError(t, err)
+				defer hash.Conn.Close()
+				tt.setup(t, s, hash)
+			}
+			if tt.save {
+				require.NoError(t, rdb.SaveOrder(ctx, order))
+			}
+			got, err := rdb.GetOrder(ctx, order.OrderUID)
+
+			// Assert expectations
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				return
+			}
+			assert.Equal(t, order.Items, got.Items)
+			assert.Equal(t, order.Payment, got.Payment)
+			// only hash layout loses sub-second precision
+			if tt.save {
+				assert.True(t, order.DateCreated.Equal(got.DateCreated))
+			}
+			assert.True(t, s.Exists(blobKey))
+			assert.False(t, s.Exists("order:"+order.OrderUID))
+		})
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slogdiscard.NewDiscardLogger()
+
+	s := miniredis.RunT(t)
+	conf := config.RedisConfig{Host: s.Host(), Port: s.Port(), Layout: redisclient.LayoutHash}
+	writer, err := redisclient.InitRedis(conf, logger)
+	require.NoError(t, err)
+	defer writer.Conn.Close()
+	reader, err := redisclient.InitRedis(conf, logger)
+	require.NoError(t, err)
+	defer reader.Conn.Close()
+
+	evicted := make(chan string, 10)
+	flushed := make(chan struct{}, 10)
+	go reader.SubscribeInvalidations(ctx, logger,
+		func(orderUID string) { evicted <- orderUID },
+		func() { flushed <- struct{}{} })
+	waitSubscribed := func() {
+		require.Eventually(t, func() bool {
+			return s.PubSubNumSub("orders:invalidate")["orders:invalidate"] == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+	waitSubscribed()
+
+	// Assert other instances evict changed orders
+	require.NoError(t, writer.SaveOrder(ctx, order))
+	assert.Equal(t, order.OrderUID, receive(t, evicted))
+	require.NoError(t, writer.DeleteOrder(ctx, order.OrderUID))
+	assert.Equal(t, order.OrderUID, receive(t, evicted))
+
+	// Assert own writes are ignored
+	require.NoError(t, reader.SaveOrder(ctx, order))
+	select {
+	case uid := <-evicted:
+		t.Fatalf("own write evicted %s", uid)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Assert local cache is flushed when the subscription drops and once it is back
+	s.Close()
+	receive(t, flushed)
+	require.NoError(t, s.Restart())
+	receive(t, flushed)
+	waitSubscribed()
+	require.NoError(t, writer.SaveOrder(ctx, order))
+	assert.Equal(t, order.OrderUID, receive(t, evicted))
+}
+
+func receive[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting")
+	}
+	var zero T
+	return zero
+}
+
+func TestDeleteOrder(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	other := generator.GenerateFakeOrder()
+	other.CustomerID = order.CustomerID
+	customerKey := "customerorders:" + order.CustomerID
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name   string
+		layout string
+		keys   []string
+	}{
+		{
+			name:   "Hash layout",
+			layout: redisclient.LayoutHash,
+			keys: []string{"order:" + order.OrderUID, "order:" + order.OrderUID + ":delivery",
+				"order:" + order.OrderUID + ":payment", "order:" + order.OrderUID + ":items"},
+		},
+		{
+			name:   "Blob layout",
+			layout: redisclient.LayoutBlob,
+			keys:   []string{"orderblob:" + order.OrderUID},
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			rdb, err := redisclient.InitRedis(config.RedisConfig{Host: s.Host(), Port: s.Port(), Layout: tt.layout},
+				slogdiscard.NewDiscardLogger())
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+			require.NoError(t, rdb.SaveOrder(ctx, order))
+			require.NoError(t, rdb.SaveOrder(ctx, other))
+
+			require.NoError(t, rdb.DeleteOrder(ctx, order.OrderUID))
+			// deleting an order that is not cached is a no-op
+			require.NoError(t, rdb.DeleteOrder(ctx, order.OrderUID))
+
+			// Assert expectations
+			for _, key := range tt.keys {
+				assert.False(t, s.Exists(key), key)
+			}
+			members, err := s.ZMembers(customerKey)
+			require.NoError(t, err)
+			assert.Equal(t, []string{other.OrderUID}, members)
+		})
+	}
+}
+
+func TestCustomerIndex(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	other := generator.GenerateFakeOrder()
+	other.CustomerID = order.CustomerID
+	moved := order
+	moved.CustomerID = "moved"
+	customerKey := "customerorders:" + order.CustomerID
+	movedKey := "customerorders:" + moved.CustomerID
+
+	ctx := context.Background()
+
+	// Run test cases
+	for _, layout := range []string{redisclient.LayoutHash, redisclient.LayoutBlob} {
+		t.Run(layout, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			rdb, err := redisclient.InitRedis(config.RedisConfig{Host: s.Host(), Port: s.Port(),
+				Layout: layout, OrderTTL: time.Hour}, slogdiscard.NewDiscardLogger())
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+
+			// index of an order whose keys already expired, and the plain set of earlier versions
+			_, err = s.ZAdd(customerKey, float64(time.Now().Add(-time.Minute).UnixMilli()), "expired")
+			require.NoError(t, err)
+			_, err = s.SetAdd("customer:"+order.CustomerID+":orders", "legacy")
+			require.NoError(t, err)
+
+			require.NoError(t, rdb.SaveOrder(ctx, order))
+			require.NoError(t, rdb.SaveOrder(ctx, other))
+			require.NoError(t, rdb.SaveOrder(ctx, moved))
+
+			// Assert expectations
+			members, err := s.ZMembers(customerKey)
+			require.NoError(t, err)
+			assert.Equal(t, []string{other.OrderUID}, members)
+			members, err = s.ZMembers(movedKey)
+			require.NoError(t, err)
+			assert.Equal(t, []string{order.OrderUID}, members)
+			assert.False(t, s.Exists("customer:"+order.CustomerID+":orders"))
+
+			// members are scored by the expiry of their order
+			score, err := s.ZScore(movedKey, order.OrderUID)
+			require.NoError(t, err)
+			assert.InDelta(t, float64(time.Now().Add(time.Hour).UnixMilli()), score, float64(time.Minute.Milliseconds()))
+			assert.Equal(t, time.Hour, s.TTL(movedKey))
+		})
+	}
+}
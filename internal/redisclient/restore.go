@@ -0,0 +1,57 @@
+package redisclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+)
+
+// number of orders read from db at once by RestoreCacheFromDB, progress is logged after each batch
+const restoreBatchSize = 500
+
+// OrderSource streams orders the cache is restored from
+type OrderSource interface {
+	// RecentOrders iterates over orders created since the given time (all of them
+	// for zero time) from newest to oldest, reading batchSize orders at once
+	RecentOrders(ctx context.Context, since time.Time, batchSize int) iter.Seq2[order_struct.Order, error]
+}
+
+// RestoreOrders saves orders created since the given time, at most limit of the
+// newest ones when limit is positive. A failed save is logged and skipped, reading
+// error or cancelled ctx stops the restore. Returns the number of saved orders.
+func RestoreOrders(ctx context.Context, log *slog.Logger, src OrderSource, since time.Time, limit int,
+	save func(ctx context.Context, order order_struct.Order) error) (int, error) {
+	start := time.Now()
+	restored, failed := 0, 0
+	defer func() { metrics.CacheRestore(start, restored) }()
+
+	log.Info("Restoring cache from db", slog.Time("since", since), slog.Int("limit", limit))
+	for order, err := range src.RecentOrders(ctx, since, restoreBatchSize) {
+		if err != nil {
+			return restored, fmt.Errorf("error reading orders: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return restored, err
+		}
+		if limit > 0 && restored >= limit {
+			break
+		}
+		if err := save(ctx, order); err != nil {
+			failed++
+			log.Error(fmt.Sprintf("Error caching order %s: %v", order.OrderUID, err))
+			continue
+		}
+		restored++
+		if restored%restoreBatchSize == 0 {
+			log.Info("Cache restore progress", slog.Int("orders", restored), slog.Int("failed", failed))
+		}
+	}
+	log.Info("Cache successfully recovered from db",
+		slog.Int("orders", restored), slog.Int("failed", failed), slog.Duration("took", time.Since(start)))
+	return restored, nil
+}
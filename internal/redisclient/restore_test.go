@@ -0,0 +1,201 @@
+package redisclient_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func orderSeq(orders []order_struct.Order, err error) iter.Seq2[order_struct.Order, error] {
+	return func(yield func(order_struct.Order, error) bool) {
+		for _, order := range orders {
+			if !yield(order, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(order_struct.Order{}, err)
+		}
+	}
+}
+
+func TestRestoreOrders(t *testing.T) {
+
+	orders := make([]order_struct.Order, 5)
+	for i := range orders {
+		orders[i] = generator.GenerateFakeOrder()
+	}
+	since := time.Now().Add(-time.Hour)
+	readErr := errors.New("connection reset")
+	saveErr := errors.New("redis is down")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Define test cases
+	tests := []struct {
+		name             string
+		ctx              context.Context
+		limit            int
+		readErr          error
+		failUID          string
+		expectedRestored int
+		expectedSaved    int
+		expectedErr      error
+	}{
+		{
+			name:             "All orders",
+			ctx:              context.Background(),
+			expectedRestored: 5,
+			expectedSaved:    5,
+		},
+		{
+			name:             "Limit keeps the newest orders",
+			ctx:              context.Background(),
+			limit:            2,
+			expectedRestored: 2,
+			expectedSaved:    2,
+		},
+		{
+			name:             "Failed save is skipped",
+			ctx:              context.Background(),
+			failUID:          orders[1].OrderUID,
+			expectedRestored: 4,
+			expectedSaved:    5,
+		},
+		{
+			name:             "Reading error stops the restore",
+			ctx:              context.Background(),
+			readErr:          readErr,
+			expectedRestored: 5,
+			expectedSaved:    5,
+			expectedErr:      readErr,
+		},
+		{
+			name:        "Cancelled context",
+			ctx:         cancelled,
+			expectedErr: context.Canceled,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := mocksdb.NewDatabase(t)
+			src.On("RecentOrders", mock.Anything, since, mock.AnythingOfType("int")).
+				Return(orderSeq(orders, tt.readErr))
+
+			var saved []string
+			save := func(ctx context.Context, order order_struct.Order) error {
+				saved = append(saved, order.OrderUID)
+				if order.OrderUID == tt.failUID {
+					return saveErr
+				}
+				return nil
+			}
+
+			restored, err := redisclient.RestoreOrders(tt.ctx, slogdiscard.NewDiscardLogger(), src, since, tt.limit, save)
+
+			// Assert expectations
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedRestored, restored)
+			assert.Len(t, saved, tt.expectedSaved)
+			for i, uid := range saved {
+				assert.Equal(t, orders[i].OrderUID, uid)
+			}
+		})
+	}
+}
+
+func TestRestoreCacheFromDB(t *testing.T) {
+
+	orders := make([]order_struct.Order, 5)
+	for i := range orders {
+		orders[i] = generator.GenerateFakeOrder()
+	}
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name             string
+		conf             config.RedisConfig
+		expectedWindow   time.Duration
+		expectedRestored int
+		// key of a restored order
+		key func(uid string) string
+	}{
+		{
+			name:             "Window and limit",
+			conf:             config.RedisConfig{OrderTTL: time.Hour, RestoreWindow: 24 * time.Hour, RestoreLimit: 2},
+			expectedWindow:   24 * time.Hour,
+			expectedRestored: 2,
+			key:              func(uid string) string { return "order:" + uid },
+		},
+		{
+			name:             "Whole db",
+			conf:             config.RedisConfig{OrderTTL: time.Hour},
+			expectedRestored: 5,
+			key:              func(uid string) string { return "order:" + uid },
+		},
+		{
+			name: "Blob layout",
+			conf: config.RedisConfig{OrderTTL: time.Hour, RestoreWindow: 24 * time.Hour, RestoreLimit: 2,
+				Layout: redisclient.LayoutBlob},
+			expectedWindow:   24 * time.Hour,
+			expectedRestored: 2,
+			key:              func(uid string) string { return "orderblob:" + uid },
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			tt.conf.Host, tt.conf.Port = s.Host(), s.Port()
+			rdb, err := redisclient.InitRedis(tt.conf, slogdiscard.NewDiscardLogger())
+			require.NoError(t, err)
+			defer rdb.Conn.Close()
+
+			src := mocksdb.NewDatabase(t)
+			var since time.Time
+			src.On("RecentOrders", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).
+				Run(func(args mock.Arguments) { since = args.Get(1).(time.Time) }).
+				Return(orderSeq(orders, nil))
+
+			restored, err := rdb.RestoreCacheFromDB(ctx, slogdiscard.NewDiscardLogger(), src)
+
+			// Assert expectations
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRestored, restored)
+			if tt.expectedWindow > 0 {
+				assert.WithinDuration(t, time.Now().Add(-tt.expectedWindow), since, time.Minute)
+			} else {
+				assert.True(t, since.IsZero())
+			}
+			// the newest orders are restored and expire like any other cached order
+			for i, order := range orders {
+				key := tt.key(order.OrderUID)
+				if i >= tt.expectedRestored {
+					assert.False(t, s.Exists(key), key)
+					continue
+				}
+				assert.Equal(t, tt.conf.OrderTTL, s.TTL(key), key)
+				assert.Equal(t, tt.conf.OrderTTL, s.TTL("customerorders:"+order.CustomerID))
+			}
+		})
+	}
+}
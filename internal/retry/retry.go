@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+)
+
+// Policy describes how an operation is retried.
+// Zero Policy makes a single attempt.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// part of the delay randomised in both directions, 0..1
+	Jitter float64
+}
+
+func NewPolicy(retry_conf config.RetryConfig) Policy {
+	return Policy{
+		MaxAttempts: retry_conf.MaxAttempts,
+		BaseDelay:   retry_conf.BaseDelay,
+		MaxDelay:    retry_conf.MaxDelay,
+		Jitter:      retry_conf.Jitter,
+	}
+}
+
+// Delay returns the pause after the given failed attempt (starting from 1)
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 && delay > 0 {
+		delta := float64(delay) * p.Jitter * (2*rand.Float64() - 1)
+		delay += time.Duration(delta)
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, returns an error retryable doesn't accept,
+// attempts are exhausted or ctx is done. The last error of fn is returned.
+func Do(ctx context.Context, p Policy, retryable func(error) bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		policy   retry.Policy
+		attempt  int
+		expected time.Duration
+	}{
+		{
+			name:     "First attempt",
+			policy:   retry.Policy{BaseDelay: 100 * time.Millisecond},
+			attempt:  1,
+			expected: 100 * time.Millisecond,
+		},
+		{
+			name:     "Delay doubles",
+			policy:   retry.Policy{BaseDelay: 100 * time.Millisecond},
+			attempt:  4,
+			expected: 800 * time.Millisecond,
+		},
+		{
+			name:     "Capped by MaxDelay",
+			policy:   retry.Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 500 * time.Millisecond},
+			attempt:  4,
+			expected: 500 * time.Millisecond,
+		},
+		{
+			name:     "Cap holds for late attempts",
+			policy:   retry.Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second},
+			attempt:  1000,
+			expected: 5 * time.Second,
+		},
+		{
+			name:     "Zero policy",
+			attempt:  3,
+			expected: 0,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Assert expectations
+			assert.Equal(t, tt.expected, tt.policy.Delay(tt.attempt))
+		})
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+
+	p := retry.Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.2}
+
+	// Assert expectations
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		seen := make(map[time.Duration]bool)
+		for range 100 {
+			delay := p.Delay(attempt)
+			assert.GreaterOrEqual(t, delay, base*8/10)
+			assert.LessOrEqual(t, delay, base*12/10)
+			seen[delay] = true
+		}
+		assert.Greater(t, len(seen), 1, "delay is not randomised")
+	}
+}
+
+func TestDo(t *testing.T) {
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+	fast := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// Define test cases
+	tests := []struct {
+		name          string
+		policy        retry.Policy
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{
+			name:          "Succeeds at once",
+			policy:        fast,
+			errs:          []error{nil},
+			expectedCalls: 1,
+		},
+		{
+			name:          "Succeeds after transient failures",
+			policy:        fast,
+			errs:          []error{errTransient, errTransient, nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "Attempts are exhausted",
+			policy:        fast,
+			errs:          []error{errTransient, errTransient, errTransient, nil},
+			expectedCalls: 3,
+			expectedErr:   errTransient,
+		},
+		{
+			name:          "Error is not retryable",
+			policy:        fast,
+			errs:          []error{errPermanent, nil},
+			expectedCalls: 1,
+			expectedErr:   errPermanent,
+		},
+		{
+			name:          "Zero policy makes a single attempt",
+			errs:          []error{errTransient, nil},
+			expectedCalls: 1,
+			expectedErr:   errTransient,
+		},
+		{
+			name:          "Negative MaxAttempts makes a single attempt",
+			policy:        retry.Policy{MaxAttempts: -1, BaseDelay: time.Millisecond},
+			errs:          []error{errTransient, nil},
+			expectedCalls: 1,
+			expectedErr:   errTransient,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retry.Do(context.Background(), tt.policy, retryable, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedCalls, calls)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
+
+func TestDoCancel(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	errTransient := errors.New("transient")
+
+	calls := 0
+	start := time.Now()
+	err := retry.Do(ctx, retry.Policy{MaxAttempts: 10, BaseDelay: time.Minute},
+		func(error) bool { return true },
+		func() error {
+			calls++
+			return errTransient
+		})
+
+	// Assert expectations
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, errTransient, err)
+}
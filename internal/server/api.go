@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+)
+
+// error codes of the API error envelope
+const (
+	codeBadRequest  = "bad_request"
+	codeNotFound    = "not_found"
+	codeConflict    = "conflict"
+	codeUnavailable = "unavailable"
+	codeInternal    = "internal"
+)
+
+// ErrorBody is the JSON envelope of every API error
+type ErrorBody struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// OrdersPage is the JSON response of order list endpoints
+type OrdersPage struct {
+	Orders     []order_struct.Order `json:"orders"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// RegisterAPI adds versioned JSON endpoints to mux
+func RegisterAPI(mux *http.ServeMux, rdb redisclient.CacheClient, db storage.Database) {
+	mux.HandleFunc("GET /api/v1/orders/{uid}", OrderAPIHandler(rdb, db))
+	mux.HandleFunc("GET /api/v1/orders", OrdersAPIHandler(db))
+	mux.HandleFunc("GET /api/v1/customers/{id}/orders", CustomerOrdersAPIHandler(db))
+}
+
+// GET /api/v1/orders/{uid}
+func OrderAPIHandler(rdb redisclient.CacheClient, db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		order, err := GetOrder(ctx, rdb, db, r.PathValue("uid"))
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, order)
+	}
+}
+
+// GET /api/v1/orders?customer_id=&delivery_service=&locale=&payment_provider=&from=&to=&cursor=&limit=
+func OrdersAPIHandler(db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		filter, err := parseOrderFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		listOrders(ctx, w, db, filter)
+	}
+}
+
+// GET /api/v1/customers/{id}/orders?from=&to=&cursor=&limit=
+func CustomerOrdersAPIHandler(db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		filter, err := parseOrderFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		filter.CustomerID = r.PathValue("id")
+		listOrders(ctx, w, db, filter)
+	}
+}
+
+func listOrders(ctx context.Context, w http.ResponseWriter, db storage.Database, filter storage.OrderFilter) {
+	page, err := db.ListOrders(ctx, filter)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	if page.Orders == nil {
+		page.Orders = []order_struct.Order{}
+	}
+	writeJSON(w, http.StatusOK, OrdersPage{Orders: page.Orders, NextCursor: page.NextCursor})
+}
+
+func parseOrderFilter(r *http.Request) (storage.OrderFilter, error) {
+	q := r.URL.Query()
+	filter := storage.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		PaymentProvider: q.Get("payment_provider"),
+		Cursor:          q.Get("cursor"),
+	}
+
+	var err error
+	if filter.From, err = parseTime(q.Get("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseTime(q.Get("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 {
+			return filter, fmt.Errorf("invalid limit: %q", limit)
+		}
+	}
+	return filter, nil
+}
+
+// accepts RFC 3339 timestamps and plain dates
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// maps storage errors to API statuses
+func writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, "order not found")
+	case errors.Is(err, storage.ErrInvalidCursor):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case storage.IsTransient(err):
+		log.Printf("Storage is unavailable: %v\n", err)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "storage is temporarily unavailable")
+	default:
+		log.Printf("Storage error: %v\n", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "internal error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorBody{Error: APIError{Code: code, Message: message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
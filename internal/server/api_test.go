@@ -0,0 +1,142 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	uid := order.OrderUID
+
+	ctx := context.Background()
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		url            string
+		mockDBSetup    func(mockDB *mocksdb.Database)
+		mockCacheSetup func(mockCache *mocksredis.CacheClient)
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:        "Order found",
+			url:         "/api/v1/orders/" + uid,
+			mockDBSetup: func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Order not found",
+			url:  "/api/v1/orders/" + uid,
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order_struct.Order{}, storage.ErrOrderNotFound)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "not_found",
+		},
+		{
+			name: "DB unavailable",
+			url:  "/api/v1/orders/" + uid,
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order_struct.Order{}, connRefused)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "unavailable",
+		},
+		{
+			name: "List with filters",
+			url:  "/api/v1/orders?customer_id=c1&from=2024-01-01&cursor=abc&limit=10",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("ListOrders", ctx, storage.OrderFilter{CustomerID: "c1", From: from, Cursor: "abc", Limit: 10}).
+					Return(storage.OrderPage{Orders: []order_struct.Order{order}, NextCursor: "def"}, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "List with bad date",
+			url:            "/api/v1/orders?from=yesterday",
+			mockDBSetup:    func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "bad_request",
+		},
+		{
+			name: "Customer orders with bad cursor",
+			url:  "/api/v1/customers/c1/orders?cursor=zzz",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("ListOrders", ctx, storage.OrderFilter{CustomerID: "c1", Cursor: "zzz"}).
+					Return(storage.OrderPage{}, fmt.Errorf("%w: bad base64", storage.ErrInvalidCursor))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "bad_request",
+		},
+		{
+			name: "List fails",
+			url:  "/api/v1/orders",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("ListOrders", ctx, storage.OrderFilter{}).Return(storage.OrderPage{}, errors.New("DB error"))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "internal",
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mocks
+			mockCache := mocksredis.NewCacheClient(t)
+			mockDB := mocksdb.NewDatabase(t)
+
+			// Set up expectations
+			tt.mockDBSetup(mockDB)
+			tt.mockCacheSetup(mockCache)
+
+			mux := http.NewServeMux()
+			server.RegisterAPI(mux, mockCache, mockDB)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedCode != "" {
+				var body server.ErrorBody
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.Equal(t, tt.expectedCode, body.Error.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/feed"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// orders buffered per live feed client before they are dropped
+	feedBuffer = 64
+	// keeps idle connections from being closed by proxies
+	feedHeartbeat = 15 * time.Second
+	feedWriteWait = 5 * time.Second
+)
+
+// line breaks would end an SSE field early and let the rest pass for another field
+var sseLineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// RegisterFeed adds live order feed endpoints and page to mux
+func RegisterFeed(mux *http.ServeMux, ctx context.Context, broadcaster *feed.Broadcaster) {
+	mux.HandleFunc("GET /api/v1/feed/sse", FeedSSEHandler(ctx, broadcaster))
+	mux.HandleFunc("GET /api/v1/feed/ws", FeedWSHandler(ctx, broadcaster))
+	mux.HandleFunc("GET /live", func(w http.ResponseWriter, r *http.Request) {
+		render(w, http.StatusOK, "live", parseFeedFilter(r))
+	})
+}
+
+func parseFeedFilter(r *http.Request) feed.Filter {
+	q := r.URL.Query()
+	return feed.Filter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		Entry:           q.Get("entry"),
+	}
+}
+
+// GET /api/v1/feed/sse?customer_id=&delivery_service=&entry=
+func FeedSSEHandler(ctx context.Context, broadcaster *feed.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, codeInternal, "streaming is not supported")
+			return
+		}
+
+		sub := broadcaster.Subscribe(parseFeedFilter(r), feedBuffer)
+		defer broadcaster.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(feedHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case order := <-sub.C:
+				data, err := json.Marshal(order)
+				if err != nil {
+					log.Printf("Error encoding feed order: %v\n", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: order\nid: %s\ndata: %s\n\n", sseLineBreaks.Replace(order.OrderUID), data); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// GET /api/v1/feed/ws?customer_id=&delivery_service=&entry=
+func FeedWSHandler(ctx context.Context, broadcaster *feed.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// upgrader has already answered with an error
+			log.Printf("Websocket upgrade error: %v\n", err)
+			return
+		}
+		defer conn.Close()
+
+		sub := broadcaster.Subscribe(parseFeedFilter(r), feedBuffer)
+		defer broadcaster.Unsubscribe(sub)
+
+		// client messages are ignored, reading is needed to notice the close
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		heartbeat := time.NewTicker(feedHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case order := <-sub.C:
+				conn.SetWriteDeadline(time.Now().Add(feedWriteWait))
+				if err := conn.WriteJSON(order); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteWait)); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			case <-ctx.Done():
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+					time.Now().Add(feedWriteWait))
+				return
+			}
+		}
+	}
+}
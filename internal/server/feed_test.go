@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/feed"
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedSSE(t *testing.T) {
+
+	broadcaster := feed.NewBroadcaster()
+	ts := httptest.NewServer(server.FeedSSEHandler(context.Background(), broadcaster))
+	defer ts.Close()
+
+	other := generator.GenerateFakeOrder()
+	other.CustomerID = "other"
+	matching := generator.GenerateFakeOrder()
+	matching.CustomerID = "customer"
+	matching.OrderUID = "uid\r\nevent: injected\ndata: {}"
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	defer disconnect()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?customer_id=customer", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return broadcaster.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+	broadcaster.Publish(other)
+	broadcaster.Publish(matching)
+
+	// the first event is the matching order, line breaks of its uid are dropped
+	var fields []string
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && lines.Text() != "" {
+		fields = append(fields, lines.Text())
+	}
+	require.NoError(t, lines.Err())
+
+	// Assert expectations
+	require.Len(t, fields, 3)
+	assert.Equal(t, "event: order", fields[0])
+	assert.Equal(t, "id: uidevent: injecteddata: {}", fields[1])
+	var received order_struct.Order
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(fields[2], "data: ")), &received))
+	assert.Equal(t, matching.OrderUID, received.OrderUID)
+
+	// subscription ends with the client connection
+	disconnect()
+	assert.Eventually(t, func() bool { return broadcaster.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
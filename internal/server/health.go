@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/EgorcaA/create_db/internal/health"
+)
+
+// RegisterHealth adds liveness and readiness endpoints to mux
+func RegisterHealth(mux *http.ServeMux, checker *health.Checker) {
+	mux.HandleFunc("GET /healthz", HealthzHandler)
+	mux.HandleFunc("GET /readyz", ReadyzHandler(checker))
+}
+
+// GET /healthz answers while the process is able to serve requests at all
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusUp})
+}
+
+// GET /readyz reports every dependency, 503 if any of them is down
+func ReadyzHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	}
+}
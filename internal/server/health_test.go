@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/health"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+
+	up := func(ctx context.Context) (any, error) { return nil, nil }
+	down := func(ctx context.Context) (any, error) { return nil, errors.New("connection refused") }
+	slow := func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		url            string
+		checks         map[string]health.Check
+		expectedStatus int
+		expectedDown   []string
+	}{
+		{
+			name:           "Liveness ignores dependencies",
+			url:            "/healthz",
+			checks:         map[string]health.Check{"postgres": down},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "All dependencies up",
+			url:            "/readyz",
+			checks:         map[string]health.Check{"postgres": up, "redis": up},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Dependency down",
+			url:            "/readyz",
+			checks:         map[string]health.Check{"postgres": up, "redis": down},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedDown:   []string{"redis"},
+		},
+		{
+			name:           "Check timed out",
+			url:            "/readyz",
+			checks:         map[string]health.Check{"postgres": slow, "redis": up},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedDown:   []string{"postgres"},
+		},
+		{
+			name:           "Startup step pending",
+			url:            "/readyz",
+			checks:         map[string]health.Check{"cache_restore": health.NewFlag("cache restore is in progress").Check},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedDown:   []string{"cache_restore"},
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(50 * time.Millisecond)
+			for name, check := range tt.checks {
+				checker.Add(name, check)
+			}
+
+			mux := http.NewServeMux()
+			server.RegisterHealth(mux, checker)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.url != "/readyz" {
+				return
+			}
+			var report health.Report
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Len(t, report.Checks, len(tt.checks))
+			for _, name := range tt.expectedDown {
+				assert.Equal(t, health.StatusDown, report.Checks[name].Status)
+				assert.NotEmpty(t, report.Checks[name].Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMetrics(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	type requestKey struct{}
+	ctx := context.WithValue(context.Background(), requestKey{}, "request")
+
+	mockCache := mocksredis.NewCacheClient(t)
+	mockDB := mocksdb.NewDatabase(t)
+	// storage is called with the request ctx, wrapped by the request span
+	mockCache.On("GetOrder", mock.MatchedBy(func(c context.Context) bool {
+		return c.Value(requestKey{}) == "request"
+	}), order.OrderUID).Return(order, nil)
+
+	mux := http.NewServeMux()
+	server.RegisterAPI(mux, mockCache, mockDB)
+	server.RegisterMetrics(mux)
+	handler := server.Instrument(mux)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/orders/"+order.OrderUID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Assert route pattern is used instead of the path
+	body := rec.Body.String()
+	assert.Contains(t, body, `l0_http_request_duration_seconds_count{method="GET",route="GET /api/v1/orders/{uid}",status="200"} 1`)
+	assert.NotContains(t, body, order.OrderUID)
+	assert.Contains(t, body, `l0_cache_lookups_total{result="hit"}`)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/EgorcaA/create_db/internal/redisclient"
+)
+
+// ErrRestoreRunning is returned by Restorer.Run while another restore is in progress
+var ErrRestoreRunning = errors.New("cache restore is already in progress")
+
+// Restorer runs cache restores one at a time, on startup and on demand
+type Restorer struct {
+	log   *slog.Logger
+	cache redisclient.CacheClient
+	src   redisclient.OrderSource
+
+	running atomic.Bool
+}
+
+func NewRestorer(log *slog.Logger, cache redisclient.CacheClient, src redisclient.OrderSource) *Restorer {
+	return &Restorer{log: log, cache: cache, src: src}
+}
+
+// Run restores the cache and returns the number of restored orders
+func (rs *Restorer) Run(ctx context.Context) (int, error) {
+	if !rs.running.CompareAndSwap(false, true) {
+		return 0, ErrRestoreRunning
+	}
+	defer rs.running.Store(false)
+	return rs.cache.RestoreCacheFromDB(ctx, rs.log, rs.src)
+}
+
+// Start runs the restore in background, the error is only about starting it
+func (rs *Restorer) Start(ctx context.Context) error {
+	if !rs.running.CompareAndSwap(false, true) {
+		return ErrRestoreRunning
+	}
+	go func() {
+		defer rs.running.Store(false)
+		if _, err := rs.cache.RestoreCacheFromDB(ctx, rs.log, rs.src); err != nil {
+			rs.log.Error(fmt.Sprintf("Cache restore failed: %v", err))
+		}
+	}()
+	return nil
+}
+
+// RegisterRestore adds the on-demand cache restore endpoint to mux
+func RegisterRestore(mux *http.ServeMux, ctx context.Context, restorer *Restorer) {
+	mux.HandleFunc("POST /api/v1/cache/restore", RestoreHandler(ctx, restorer))
+}
+
+// POST /api/v1/cache/restore
+// restore runs with the server ctx, so it outlives the request
+func RestoreHandler(ctx context.Context, restorer *Restorer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := restorer.Start(ctx); err != nil {
+			writeError(w, http.StatusConflict, codeConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+	}
+}
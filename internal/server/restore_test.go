@@ -0,0 +1,65 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRestore(t *testing.T) {
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		running        bool
+		expectedStatus int
+	}{
+		{
+			name:           "Restore is started",
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Restore is already running",
+			running:        true,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := mocksredis.NewCacheClient(t)
+			db := mocksdb.NewDatabase(t)
+			restorer := server.NewRestorer(slogdiscard.NewDiscardLogger(), cache, db)
+
+			// restore blocks until released, so the request sees it running
+			release := make(chan struct{})
+			done := make(chan struct{}, 2)
+			cache.On("RestoreCacheFromDB", mock.Anything, mock.Anything, db).
+				Run(func(mock.Arguments) { <-release; done <- struct{}{} }).
+				Return(0, nil)
+			if tt.running {
+				assert.NoError(t, restorer.Start(context.Background()))
+			}
+
+			mux := http.NewServeMux()
+			server.RegisterRestore(mux, context.Background(), restorer)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/cache/restore", nil))
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			close(release)
+			<-done
+			cache.AssertNumberOfCalls(t, "RestoreCacheFromDB", 1)
+		})
+	}
+}
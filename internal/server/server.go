@@ -2,58 +2,85 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/EgorcaA/create_db/internal/metrics"
+	"github.com/EgorcaA/create_db/internal/order_struct"
 	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Main HTML-form
+// Main page with search forms
 func IndexHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := `
-	<!DOCTYPE html>
-	<html lang="en">
-	<head>
-		<meta charset="UTF-8">
-		<meta name="viewport" content="width=device-width, initial-scale=1.0">
-		<title>Найти заказ</title>
-	</head>
-	<body>
-		<h1>Введите OrderUID</h1>
-		<form action="/user" method="POST">
-			<label for="id">OrderUID:</label>
-			<input type="string" OrderUID="OrderUID" name="OrderUID" required>
-			<button type="submit">Отправить</button>
-		</form>
-	</body>
-	</html>
-	`
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, tmpl)
+	// "/" pattern catches every unknown path
+	if r.URL.Path != "/" {
+		renderError(w, http.StatusNotFound, "Страница не найдена", r.URL.Path)
+		return
+	}
+	render(w, http.StatusOK, "index", nil)
 }
 
-// Order retrieve handler
-func OrderHandler(ctx context.Context, rdb redisclient.CacheClient) http.HandlerFunc {
+// Order retrieve handler of the HTML form, answers like GET /api/v1/orders/{uid}
+func OrderHandler(rdb redisclient.CacheClient, db storage.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		if r.Method != http.MethodPost {
-			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, codeBadRequest, "метод не поддерживается")
 			return
 		}
 
 		OrderUID := r.FormValue("OrderUID")
+		if OrderUID == "" {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "OrderUID is required")
+			return
+		}
 
-		// getting order from cache
-		order, err := rdb.GetOrder(ctx, OrderUID)
-		if err != nil || order.OrderUID == "" {
-			http.Error(w, "DB internal error", http.StatusInternalServerError)
-			log.Printf("Cache search error: %v\n", err)
+		order, err := GetOrder(ctx, rdb, db, OrderUID)
+		if err != nil {
+			writeStorageError(w, err)
 			return
 		}
+		writeJSON(w, http.StatusOK, order)
+	}
+}
+
+// GetOrder reads the order from cache falling back to db on a miss,
+// the order found in db is put back to cache
+func GetOrder(ctx context.Context, rdb redisclient.CacheClient, db storage.Database,
+	orderUID string) (order_struct.Order, error) {
+	// cache result is recorded on the request span
+	span := trace.SpanFromContext(ctx)
+
+	// getting order from cache
+	order, err := rdb.GetOrder(ctx, orderUID)
+	if err == nil {
+		metrics.CacheLookup(metrics.CacheHit)
+		span.SetAttributes(attribute.String("cache", metrics.CacheHit))
+		return order, nil
+	}
+	if errors.Is(err, redisclient.ErrCacheMiss) {
+		metrics.CacheLookup(metrics.CacheMiss)
+		span.SetAttributes(attribute.String("cache", metrics.CacheMiss))
+	} else {
+		// cache is unavailable, db still can answer
+		metrics.CacheLookup(metrics.CacheFallback)
+		span.SetAttributes(attribute.String("cache", metrics.CacheFallback))
+		log.Printf("Cache search error: %v\n", err)
+	}
+
+	order, err = db.GetOrderByUID(ctx, orderUID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return order, err
+	}
 
-		// return
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(order)
+	if err := rdb.SaveOrder(ctx, order); err != nil {
+		log.Printf("Error caching order %s: %v\n", orderUID, err)
 	}
+	return order, nil
 }
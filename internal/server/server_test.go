@@ -0,0 +1,115 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderHandler(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+	uid := order.OrderUID
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		mockDBSetup    func(mockDB *mocksdb.Database)
+		mockCacheSetup func(mockCache *mocksredis.CacheClient)
+		expectedStatus int
+	}{
+		{
+			name:        "Cache hit",
+			mockDBSetup: func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Cache miss falls back to DB and recaches",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+				mockCache.On("SaveOrder", ctx, order).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Cache unavailable falls back to DB",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, errors.New("connection refused"))
+				mockCache.On("SaveOrder", ctx, order).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Order is in neither store",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order_struct.Order{},
+					fmt.Errorf("%w: %s", storage.ErrOrderNotFound, uid))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "DB fails",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByUID", ctx, uid).Return(order_struct.Order{}, errors.New("DB error"))
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, uid).Return(order_struct.Order{}, redisclient.ErrCacheMiss)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mocks
+			mockCache := mocksredis.NewCacheClient(t)
+			mockDB := mocksdb.NewDatabase(t)
+
+			// Set up expectations
+			tt.mockDBSetup(mockDB)
+			tt.mockCacheSetup(mockCache)
+
+			form := url.Values{"OrderUID": {uid}}
+			req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+
+			// Call the handler
+			server.OrderHandler(mockCache, mockDB)(rec, req)
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			mockDB.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
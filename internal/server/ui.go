@@ -0,0 +1,154 @@
+package server
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/redisclient"
+	"github.com/EgorcaA/create_db/internal/storage"
+)
+
+//go:embed web/templates/*.html web/static/*
+var webFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"unixtime": func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02 15:04:05") },
+	"itemsPrice": func(items []order_struct.Item) int {
+		sum := 0
+		for _, item := range items {
+			sum += item.Price
+		}
+		return sum
+	},
+	"itemsTotal": func(items []order_struct.Item) int {
+		sum := 0
+		for _, item := range items {
+			sum += item.TotalPrice
+		}
+		return sum
+	},
+}
+
+// every page is parsed together with the layout, pages define "title" and "content"
+var pages = map[string]*template.Template{
+	"index":    parsePage("index"),
+	"order":    parsePage("order"),
+	"customer": parsePage("customer"),
+	"error":    parsePage("error"),
+	"live":     parsePage("live"),
+}
+
+func parsePage(name string) *template.Template {
+	return template.Must(template.New(name).Funcs(templateFuncs).
+		ParseFS(webFS, "web/templates/layout.html", "web/templates/"+name+".html"))
+}
+
+// data of the customer page
+type customerPage struct {
+	CustomerID string
+	Orders     []order_struct.Order
+	NextCursor string
+}
+
+// data of the error page
+type errorPage struct {
+	Title   string
+	Message string
+}
+
+// RegisterUI adds HTML pages and static assets to mux
+func RegisterUI(mux *http.ServeMux, rdb redisclient.CacheClient, db storage.Database) {
+	static, _ := fs.Sub(webFS, "web/static")
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /order", OrderPageHandler(rdb, db))
+	mux.HandleFunc("GET /track", TrackPageHandler(db))
+	mux.HandleFunc("GET /customer", CustomerPageHandler(db))
+}
+
+// GET /order?uid=
+func OrderPageHandler(rdb redisclient.CacheClient, db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		uid := r.URL.Query().Get("uid")
+		if uid == "" {
+			renderError(w, http.StatusBadRequest, "Некорректный запрос", "OrderUID is required")
+			return
+		}
+		order, err := GetOrder(ctx, rdb, db, uid)
+		if err != nil {
+			renderStorageError(w, err, "Заказ "+uid+" не найден")
+			return
+		}
+		render(w, http.StatusOK, "order", order)
+	}
+}
+
+// GET /track?number=
+func TrackPageHandler(db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		number := r.URL.Query().Get("number")
+		if number == "" {
+			renderError(w, http.StatusBadRequest, "Некорректный запрос", "track number is required")
+			return
+		}
+		order, err := db.GetOrderByTrackNumber(ctx, number)
+		if err != nil {
+			renderStorageError(w, err, "Заказ с трек-номером "+number+" не найден")
+			return
+		}
+		render(w, http.StatusOK, "order", order)
+	}
+}
+
+// GET /customer?id=&cursor=
+func CustomerPageHandler(db storage.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			renderError(w, http.StatusBadRequest, "Некорректный запрос", "customer id is required")
+			return
+		}
+		page, err := db.ListOrders(ctx, storage.OrderFilter{CustomerID: id, Cursor: r.URL.Query().Get("cursor")})
+		if err != nil {
+			renderStorageError(w, err, "")
+			return
+		}
+		render(w, http.StatusOK, "customer", customerPage{CustomerID: id, Orders: page.Orders, NextCursor: page.NextCursor})
+	}
+}
+
+func renderStorageError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		renderError(w, http.StatusNotFound, "Заказ не найден", notFound)
+	case errors.Is(err, storage.ErrInvalidCursor):
+		renderError(w, http.StatusBadRequest, "Некорректный запрос", err.Error())
+	case storage.IsTransient(err):
+		log.Printf("Storage is unavailable: %v\n", err)
+		renderError(w, http.StatusServiceUnavailable, "Сервис недоступен", "Хранилище временно недоступно, попробуйте позже")
+	default:
+		log.Printf("Storage error: %v\n", err)
+		renderError(w, http.StatusInternalServerError, "Ошибка", "Внутренняя ошибка сервиса")
+	}
+}
+
+func renderError(w http.ResponseWriter, status int, title, message string) {
+	render(w, status, "error", errorPage{Title: title, Message: message})
+}
+
+func render(w http.ResponseWriter, status int, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Error rendering page %s: %v\n", page, err)
+	}
+}
@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	mocksredis "github.com/EgorcaA/create_db/internal/mocks/CacheClient"
+	mocksdb "github.com/EgorcaA/create_db/internal/mocks/Database"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/server"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUI(t *testing.T) {
+
+	order := generator.GenerateFakeOrder()
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		url            string
+		mockDBSetup    func(mockDB *mocksdb.Database)
+		mockCacheSetup func(mockCache *mocksredis.CacheClient)
+		expectedStatus int
+		expectedText   string
+	}{
+		{
+			name:        "Order page",
+			url:         "/order?uid=" + order.OrderUID,
+			mockDBSetup: func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {
+				mockCache.On("GetOrder", ctx, order.OrderUID).Return(order, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedText:   order.Payment.Transaction,
+		},
+		{
+			name: "Track number not found",
+			url:  "/track?number=missing",
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("GetOrderByTrackNumber", ctx, "missing").Return(order_struct.Order{}, storage.ErrOrderNotFound)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusNotFound,
+			expectedText:   "Заказ с трек-номером missing не найден",
+		},
+		{
+			name: "Customer orders",
+			url:  "/customer?id=" + order.CustomerID,
+			mockDBSetup: func(mockDB *mocksdb.Database) {
+				mockDB.On("ListOrders", ctx, storage.OrderFilter{CustomerID: order.CustomerID}).
+					Return(storage.OrderPage{Orders: []order_struct.Order{order}, NextCursor: "next"}, nil)
+			},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusOK,
+			expectedText:   "cursor=next",
+		},
+		{
+			name:           "Static assets",
+			url:            "/static/style.css",
+			mockDBSetup:    func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusOK,
+			expectedText:   "table.items",
+		},
+		{
+			name:           "Unknown page",
+			url:            "/nothing",
+			mockDBSetup:    func(mockDB *mocksdb.Database) {},
+			mockCacheSetup: func(mockCache *mocksredis.CacheClient) {},
+			expectedStatus: http.StatusNotFound,
+			expectedText:   "Страница не найдена",
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mocks
+			mockCache := mocksredis.NewCacheClient(t)
+			mockDB := mocksdb.NewDatabase(t)
+
+			// Set up expectations
+			tt.mockDBSetup(mockDB)
+			tt.mockCacheSetup(mockCache)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", server.IndexHandler)
+			server.RegisterUI(mux, mockCache, mockDB)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedText)
+		})
+	}
+}
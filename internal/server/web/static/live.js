@@ -0,0 +1,47 @@
+// Live order feed: listens to the SSE endpoint with the filters of the page
+(function () {
+	const maxRows = 200;
+	const rows = document.getElementById("orders");
+	const status = document.getElementById("status");
+
+	function cell(tr, text, className) {
+		const td = document.createElement("td");
+		td.textContent = text;
+		if (className) {
+			td.className = className;
+		}
+		tr.appendChild(td);
+		return td;
+	}
+
+	function addOrder(order) {
+		const tr = document.createElement("tr");
+		cell(tr, new Date(order.date_created).toLocaleString());
+		const uid = cell(tr, "");
+		const link = document.createElement("a");
+		link.href = "/order?uid=" + encodeURIComponent(order.order_uid);
+		link.textContent = order.order_uid;
+		uid.appendChild(link);
+		cell(tr, order.customer_id);
+		cell(tr, order.delivery_service);
+		cell(tr, order.entry);
+		cell(tr, (order.items || []).length, "num");
+		cell(tr, order.payment.amount + " " + order.payment.currency, "num");
+
+		rows.insertBefore(tr, rows.firstChild);
+		while (rows.children.length > maxRows) {
+			rows.removeChild(rows.lastChild);
+		}
+	}
+
+	const source = new EventSource("/api/v1/feed/sse" + window.location.search);
+	source.onopen = function () {
+		status.textContent = "онлайн";
+	};
+	source.onerror = function () {
+		status.textContent = "переподключение…";
+	};
+	source.addEventListener("order", function (e) {
+		addOrder(JSON.parse(e.data));
+	});
+})();
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	// order with the same order_uid is already stored
+	ErrDuplicateOrder = errors.New("order already exists")
+	// order is rejected by the schema constraints, retrying won't help
+	ErrInvalidOrder = errors.New("order violates db constraints")
+)
+
+// wraps postgres and sqlite errors into storage sentinel errors,
+// everything not recognised is left as is and treated as transient by callers
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		// sqlite names the failed columns only in the message
+		case (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) &&
+			strings.Contains(sqliteErr.Error(), "orders.order_uid"):
+			return fmt.Errorf("%w: %v", ErrDuplicateOrder, err)
+		// extended codes keep the primary one in the low byte
+		case sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT, sqliteErr.Code()&0xff == sqlite3.SQLITE_MISMATCH:
+			return fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+		}
+		return err
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch {
+	// other unique keys, e.g. the payment transaction, collide with a different order
+	case pqErr.Code.Name() == "unique_violation" && pqErr.Constraint == "orders_pkey":
+		return fmt.Errorf("%w: %v", ErrDuplicateOrder, err)
+	// class 22 - data exception, class 23 - integrity constraint violation
+	case pqErr.Code.Class() == "22", pqErr.Code.Class() == "23":
+		return fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+	return err
+}
+
+// IsTransient reports whether the operation failed because of the connection
+// or a concurrency conflict and may succeed if retried.
+// Constraint violations and bad data are permanent.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, ErrDuplicateOrder) || errors.Is(err, ErrInvalidOrder) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return true
+		}
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		// connection exception, transaction rollback (serialization failure, deadlock),
+		// insufficient resources
+		case "08", "40", "53":
+			return true
+		}
+		switch pqErr.Code.Name() {
+		// query_canceled is the statement_timeout of the server as well
+		case "lock_not_available", "admin_shutdown", "crash_shutdown", "cannot_connect_now", "query_canceled":
+			return true
+		}
+	}
+	return false
+}
+
+// contextError adds the reason ctx is done to the error of a query run with it.
+// pq reports a query interrupted by ctx as query_canceled, which doesn't tell
+// a timed out query from the one its caller gave up on.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
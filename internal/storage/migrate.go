@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed migrations/*/*.sql
+var migrationsFS embed.FS
+
+// Migrator applies schema migrations embedded in the binary
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator prepares migrations of the db backend on a dedicated connection
+func (db *SQLDB) NewMigrator(ctx context.Context) (*Migrator, error) {
+	if db.dialect.name == sqliteDialect.name {
+		return db.newSQLiteMigrator()
+	}
+
+	src, err := iofs.New(migrationsFS, "migrations/postgres")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	conn, err := db.Conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db connection: %w", err)
+	}
+	// closing the driver closes conn only, db stays open
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+	return &Migrator{m: m}, nil
+}
+
+// the sqlite driver closes the db it is given, so it gets its own one
+func (db *SQLDB) newSQLiteMigrator() (*Migrator, error) {
+	src, err := iofs.New(migrationsFS, "migrations/sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite", db.dialect.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db connection: %w", err)
+	}
+	driver, err := sqlite.WithInstance(conn, &sqlite.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "sqlite", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+	return &Migrator{m: m}, nil
+}
+
+// Up applies all pending migrations
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down rolls back the given number of applied migrations
+func (mg *Migrator) Down(steps int) error {
+	if err := mg.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Version returns the applied version, zero if none are applied.
+// Dirty means the last migration failed and needs Force after a manual fix.
+func (mg *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Force sets the version without running migrations and clears the dirty flag
+func (mg *Migrator) Force(version int) error {
+	return mg.m.Force(version)
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// applies pending migrations on startup
+func (db *SQLDB) migrateUp(log *slog.Logger) error {
+	mg, err := db.NewMigrator(context.Background())
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if err := mg.Up(); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return fmt.Errorf("failed to check schema version: %w", err)
+	}
+	log.Info("Schema is up to date", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+	return nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS payment_order_uid_idx;
+DROP INDEX IF EXISTS items_order_uid_idx;
+DROP INDEX IF EXISTS orders_date_created_idx;
+DROP INDEX IF EXISTS orders_track_number_idx;
+DROP INDEX IF EXISTS orders_customer_id_idx;
@@ -0,0 +1,6 @@
+-- Indexes for lookups and keyset pagination
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+CREATE INDEX IF NOT EXISTS payment_order_uid_idx ON payment (order_uid);
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	_ "github.com/lib/pq"
+)
+
+var postgresDialect = dialect{
+	name:      "postgresql",
+	forUpdate: " FOR UPDATE OF o",
+	timeArg:   func(t time.Time) any { return t },
+}
+
+// Check if a database exists
+func databaseExists(ctx context.Context, db *sql.DB, dbName string) (bool, error) {
+	query := "SELECT 1 FROM pg_database WHERE datname = $1"
+	var exists int
+	err := db.QueryRowContext(ctx, query, dbName).Scan(&exists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// returns new postres db connection
+func NewPostgres(log *slog.Logger, Postgres_conf config.PostgresConfig, storage_conf config.StorageConfig) (*SQLDB, error) {
+	// lib/pq registers itself as "postgres"
+	if Postgres_conf.PGDriver != "pq" {
+		return nil, fmt.Errorf("unsupported PostgreSQL driver %q, only pq is available", Postgres_conf.PGDriver)
+	}
+	if Postgres_conf.User == "" || Postgres_conf.Password == "" {
+		return nil, errors.New("PostgreSQL user and password are required")
+	}
+
+	serverConnStr := fmt.Sprintf("host=%s port=%d user=%s password=%s sslmode=disable dbname=postgres",
+		Postgres_conf.Host,
+		Postgres_conf.Port,
+		Postgres_conf.User,
+		Postgres_conf.Password,
+	)
+	serverDb, err := sql.Open("postgres", serverConnStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL server: %w", err)
+	}
+	defer serverDb.Close()
+	log.Info("PostgreSQL server opened successfully!")
+
+	// setup queries are bounded like any other, an unreachable server doesn't hang the start
+	ctx := context.Background()
+	if storage_conf.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, storage_conf.QueryTimeout)
+		defer cancel()
+	}
+
+	// Check if the database exists and if not create it
+	exists, err := databaseExists(ctx, serverDb, Postgres_conf.Name)
+	if err != nil {
+		log.Warn(fmt.Sprintf("Failed to check database existence: %v", err))
+	}
+	if !exists {
+		query := fmt.Sprintf("CREATE DATABASE %s;", Postgres_conf.Name)
+		_, err = serverDb.ExecContext(ctx, query)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Error creating database %s: %v (it might already exist)", Postgres_conf.Name, err))
+		} else {
+			log.Info(fmt.Sprintf("Database %s created successfully!", Postgres_conf.Name))
+		}
+
+	}
+	// connecting db
+	serverConnStr = fmt.Sprintf("host=%s port=%d user=%s password=%s sslmode=disable dbname=%s",
+		Postgres_conf.Host,
+		Postgres_conf.Port,
+		Postgres_conf.User,
+		Postgres_conf.Password,
+		Postgres_conf.Name)
+	conn, err := sql.Open("postgres", serverConnStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL db %s: %w", Postgres_conf.Name, err)
+	}
+	conn.SetMaxOpenConns(Postgres_conf.MaxOpenConns)
+	if Postgres_conf.MaxIdleConns > 0 {
+		// zero would keep no idle connections at all
+		conn.SetMaxIdleConns(Postgres_conf.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(Postgres_conf.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(Postgres_conf.ConnMaxIdleTime)
+	log.Info(fmt.Sprintf("Successfully connected to PostgreSQL db %s", Postgres_conf.Name),
+		slog.Int("max_open_conns", Postgres_conf.MaxOpenConns),
+		slog.Int("max_idle_conns", Postgres_conf.MaxIdleConns))
+
+	db := &SQLDB{Conn: conn, dialect: postgresDialect, onConflict: storage_conf.OnConflict,
+		queryTimeout: storage_conf.QueryTimeout}
+
+	// Apply schema migrations
+	if storage_conf.AutoMigrate {
+		if err := db.migrateUp(log); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+
+}
@@ -0,0 +1,136 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPostgres(t *testing.T, onConflict string) *storage.SQLDB {
+	return openPostgres(t, config.StorageConfig{OnConflict: onConflict, AutoMigrate: true, QueryTimeout: 5 * time.Second})
+}
+
+// openPostgres creates a database of its own on the server from POSTGRES_TEST_HOST and
+// POSTGRES_TEST_PORT, the credentials are the docker-compose ones.
+// The test is skipped without a server.
+func openPostgres(t *testing.T, storage_conf config.StorageConfig) *storage.SQLDB {
+	host := os.Getenv("POSTGRES_TEST_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_TEST_HOST is not set")
+	}
+	port := 5433
+	if p := os.Getenv("POSTGRES_TEST_PORT"); p != "" {
+		var err error
+		port, err = strconv.Atoi(p)
+		require.NoError(t, err)
+	}
+	Postgres_conf := config.PostgresConfig{
+		Host:     host,
+		Port:     port,
+		User:     "user",
+		Password: "password",
+		Name:     fmt.Sprintf("orders_test_%d", time.Now().UnixNano()),
+		PGDriver: "pq",
+	}
+
+	db, err := storage.NewPostgres(slogdiscard.NewDiscardLogger(), Postgres_conf, storage_conf)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+		server, err := sql.Open("postgres", fmt.Sprintf(
+			"host=%s port=%d user=%s password=%s sslmode=disable dbname=postgres",
+			Postgres_conf.Host, Postgres_conf.Port, Postgres_conf.User, Postgres_conf.Password))
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		server.Exec("DROP DATABASE IF EXISTS " + Postgres_conf.Name)
+	})
+	return db
+}
+
+func TestPostgresInsertOrder(t *testing.T) {
+	testInsertOrder(t, newPostgres)
+}
+
+func TestPostgresListOrders(t *testing.T) {
+	testListOrders(t, newPostgres)
+}
+
+func TestPostgresListOrderPages(t *testing.T) {
+	testListOrderPages(t, newPostgres)
+}
+
+func TestPostgresQueryTimeout(t *testing.T) {
+
+	order := fakeOrder(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	db := openPostgres(t, config.StorageConfig{OnConflict: storage.ConflictUpdate, AutoMigrate: true,
+		QueryTimeout: 200 * time.Millisecond})
+
+	// the lock holds every query on orders until the query timeout cancels it on the server
+	ctx := context.Background()
+	lock, err := db.Conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer lock.Rollback()
+	_, err = lock.ExecContext(ctx, "LOCK TABLE orders IN ACCESS EXCLUSIVE MODE")
+	require.NoError(t, err)
+
+	_, insertErr := db.InsertOrder(ctx, order)
+	_, readErr := db.GetOrderByUID(ctx, order.OrderUID)
+
+	// Assert expectations
+	for _, err := range []error{insertErr, readErr} {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, storage.IsTransient(err), err)
+	}
+}
+
+func TestPostgresIsTransient(t *testing.T) {
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Statement timeout",
+			err:      &pq.Error{Code: "57014"},
+			expected: true,
+		},
+		{
+			name:     "Serialization failure",
+			err:      &pq.Error{Code: "40001"},
+			expected: true,
+		},
+		{
+			name:     "Query of a cancelled caller",
+			err:      fmt.Errorf("%w: %w", context.Canceled, &pq.Error{Code: "57014"}),
+			expected: false,
+		},
+		{
+			name:     "Syntax error",
+			err:      &pq.Error{Code: "42601"},
+			expected: false,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Assert expectations
+			assert.Equal(t, tt.expected, storage.IsTransient(tt.err))
+		})
+	}
+}
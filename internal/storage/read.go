@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+)
+
+var (
+	// ErrOrderNotFound is returned when there is no order with such order_uid
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidCursor is returned by ListOrders for a malformed cursor
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// page size limits of ListOrders
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// OrderFilter narrows ListOrders, zero fields are not applied
+type OrderFilter struct {
+	// date_created range, From inclusive, To exclusive
+	From time.Time
+	To   time.Time
+
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	PaymentProvider string
+
+	// NextCursor of the previous page, empty for the first one
+	Cursor string
+	Limit  int
+}
+
+// OrderPage is a page of orders sorted from newest to oldest
+type OrderPage struct {
+	Orders []order_struct.Order
+	// empty when there are no more orders
+	NextCursor string
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// InsertOrder writes delivery and payment in the transaction of the order,
+// an order row without them is foreign and is skipped by the inner joins
+const selectOrdersQuery = `
+	SELECT
+		o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+		o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+		d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+		p."transaction", p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+		p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+	FROM orders o
+	JOIN delivery d ON o.order_uid = d.order_uid
+	JOIN payment p ON o.order_uid = p.order_uid
+`
+
+// GetOrderByUID retrieves a single order with its delivery, payment and items
+func (db *SQLDB) GetOrderByUID(ctx context.Context, orderUID string) (order_struct.Order, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	order, err := selectOrder(ctx, db.Conn, orderUID, "")
+	return order, contextError(ctx, err)
+}
+
+// GetOrderByTrackNumber retrieves the newest order with such track number
+func (db *SQLDB) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (order_struct.Order, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	orders, err := selectOrders(ctx, db.Conn,
+		"WHERE o.track_number = $1 ORDER BY o.date_created DESC, o.order_uid DESC LIMIT 1", trackNumber)
+	if err != nil {
+		return order_struct.Order{}, contextError(ctx, err)
+	}
+	if len(orders) == 0 {
+		return order_struct.Order{}, fmt.Errorf("%w: track number %s", ErrOrderNotFound, trackNumber)
+	}
+	return orders[0], nil
+}
+
+// GetOrdersByCustomer retrieves all orders of the customer from newest to oldest
+func (db *SQLDB) GetOrdersByCustomer(ctx context.Context, customerID string) ([]order_struct.Order, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	orders, err := selectOrders(ctx, db.Conn,
+		"WHERE o.customer_id = $1 ORDER BY o.date_created DESC, o.order_uid DESC", customerID)
+	return orders, contextError(ctx, err)
+}
+
+// ListOrders retrieves a page of orders matching the filter from newest to oldest.
+// Pages are keyset based, so orders inserted meanwhile don't shift them.
+func (db *SQLDB) ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if !filter.From.IsZero() {
+		add("o.date_created >= $%d", db.dialect.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		add("o.date_created < $%d", db.dialect.timeArg(filter.To))
+	}
+	if filter.CustomerID != "" {
+		add("o.customer_id = $%d", filter.CustomerID)
+	}
+	if filter.DeliveryService != "" {
+		add("o.delivery_service = $%d", filter.DeliveryService)
+	}
+	if filter.Locale != "" {
+		add("o.locale = $%d", filter.Locale)
+	}
+	if filter.PaymentProvider != "" {
+		add("p.provider = $%d", filter.PaymentProvider)
+	}
+	if filter.Cursor != "" {
+		created, uid, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return OrderPage{}, err
+		}
+		args = append(args, db.dialect.timeArg(created), uid)
+		conds = append(conds, fmt.Sprintf("(o.date_created, o.order_uid) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	// one extra row tells whether there is a next page
+	args = append(args, limit+1)
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	orders, err := selectOrders(ctx, db.Conn,
+		fmt.Sprintf("%s ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $%d", where, len(args)), args...)
+	if err != nil {
+		return OrderPage{}, contextError(ctx, err)
+	}
+
+	page := OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = encodeCursor(last.DateCreated, last.OrderUID)
+	}
+	return page, nil
+}
+
+// cursor is the sort key of the last order of a page
+func encodeCursor(created time.Time, orderUID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(created.UTC().Format(time.RFC3339Nano) + "|" + orderUID))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	created, uid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return t, uid, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scans a row of selectOrdersQuery
+func scanOrder(row scanner) (order_struct.Order, error) {
+	var order order_struct.Order
+	err := row.Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+		&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
+		&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+		&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank, &order.Payment.DeliveryCost,
+		&order.Payment.GoodsTotal, &order.Payment.CustomFee,
+	)
+	return order, err
+}
+
+// reads stored order, lock is the dialect clause locking its row until the end of the transaction
+func selectOrder(ctx context.Context, q querier, orderUID string, lock string) (order_struct.Order, error) {
+	query := selectOrdersQuery + "WHERE o.order_uid = $1" + lock
+
+	order, err := scanOrder(q.QueryRowContext(ctx, query, orderUID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return order, fmt.Errorf("%w: %s", ErrOrderNotFound, orderUID)
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to read order %s: %w", orderUID, err)
+	}
+
+	items, err := selectItems(ctx, q, []string{orderUID})
+	if err != nil {
+		return order, err
+	}
+	order.Items = items[orderUID]
+	return order, nil
+}
+
+// reads orders matching the tail of the query (WHERE, ORDER BY, LIMIT) with their items
+func selectOrders(ctx context.Context, q querier, tail string, args ...any) ([]order_struct.Order, error) {
+	rows, err := q.QueryContext(ctx, selectOrdersQuery+tail, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []order_struct.Order
+	var uids []string
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+		uids = append(uids, order.OrderUID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	items, err := selectItems(ctx, q, uids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].Items = items[orders[i].OrderUID]
+	}
+	return orders, nil
+}
+
+// reads items of the orders keeping insertion order
+func selectItems(ctx context.Context, q querier, orderUIDs []string) (map[string][]order_struct.Item, error) {
+	// IN list works for every backend, there are at most MaxListLimit+1 orders
+	placeholders := make([]string, len(orderUIDs))
+	args := make([]any, len(orderUIDs))
+	for i, uid := range orderUIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = uid
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+		FROM items
+		WHERE order_uid IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY order_uid, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string][]order_struct.Item, len(orderUIDs))
+	for rows.Next() {
+		var orderUID string
+		var item order_struct.Item
+		err := rows.Scan(&orderUID, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name,
+			&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items[orderUID] = append(items[orderUID], item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// date_created text format, fixed width so it sorts as time
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000"
+
+var sqliteDialect = dialect{
+	name: "sqlite",
+	// writers are serialized by the database lock
+	forUpdate: "",
+	timeArg:   func(t time.Time) any { return storedTime(t).Format(sqliteTimeFormat) },
+}
+
+// NewSQLite opens the embedded database file, it is created if missing
+func NewSQLite(log *slog.Logger, path string, storage_conf config.StorageConfig) (*SQLDB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite db %s: %w", path, err)
+	}
+	// one writer at a time anyway, a single connection avoids busy errors
+	conn.SetMaxOpenConns(1)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open SQLite db %s: %w", path, err)
+	}
+	log.Info(fmt.Sprintf("Successfully opened SQLite db %s", path))
+
+	d := sqliteDialect
+	d.dsn = dsn
+	db := &SQLDB{Conn: conn, dialect: d, onConflict: storage_conf.OnConflict, queryTimeout: storage_conf.QueryTimeout}
+
+	// Apply schema migrations
+	if storage_conf.AutoMigrate {
+		if err := db.migrateUp(log); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
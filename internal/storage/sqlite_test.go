@@ -0,0 +1,155 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/logger/handlers/slogdiscard"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLite(t *testing.T, onConflict string) *storage.SQLDB {
+	return openSQLite(t, config.StorageConfig{OnConflict: onConflict, AutoMigrate: true})
+}
+
+// openSQLite opens the backend without postgres config, the way an SQLite-only setup does
+func openSQLite(t *testing.T, storage_conf config.StorageConfig) *storage.SQLDB {
+	db, err := storage.Open(slogdiscard.NewDiscardLogger(),
+		config.AppConfig{Storage: storage.StorageSQLite, StoragePath: filepath.Join(t.TempDir(), "storage.db")},
+		storage_conf, config.PostgresConfig{})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteInsertOrder(t *testing.T) {
+	testInsertOrder(t, newSQLite)
+}
+
+func TestSQLiteListOrders(t *testing.T) {
+	testListOrders(t, newSQLite)
+}
+
+func TestSQLiteListOrderPages(t *testing.T) {
+	testListOrderPages(t, newSQLite)
+}
+
+func TestSQLiteReadOrders(t *testing.T) {
+
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	order := fakeOrder(created)
+	for _, name := range []string{"second", "third"} {
+		item := order.Items[0]
+		item.Name = name
+		order.Items = append(order.Items, item)
+	}
+
+	ctx := context.Background()
+	db := newSQLite(t, storage.ConflictUpdate)
+	_, err := db.InsertOrder(ctx, order)
+	require.NoError(t, err)
+	// order row written around the service, without delivery and payment
+	_, err = db.Conn.ExecContext(ctx, `
+		INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		) VALUES ('bare', 'track', 'entry', 'en', '', 'customer', 'service', '1', 1, '2024-05-02 00:00:00.000000', '1')
+	`)
+	require.NoError(t, err)
+
+	all, err := db.GetAllOrders(ctx)
+	require.NoError(t, err)
+	page, err := db.ListOrders(ctx, storage.OrderFilter{})
+	require.NoError(t, err)
+	_, bareErr := db.GetOrderByUID(ctx, "bare")
+
+	// Assert expectations
+	expected := []order_struct.Order{order}
+	assert.Equal(t, expected, all)
+	assert.Equal(t, expected, page.Orders)
+	assert.Empty(t, page.NextCursor)
+	assert.ErrorIs(t, bareErr, storage.ErrOrderNotFound)
+}
+
+func TestSQLiteContext(t *testing.T) {
+
+	order := fakeOrder(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Define test cases
+	tests := []struct {
+		name              string
+		ctx               context.Context
+		queryTimeout      time.Duration
+		expectedErr       error
+		expectedTransient bool
+	}{
+		{
+			name:         "Query within timeout",
+			ctx:          context.Background(),
+			queryTimeout: time.Minute,
+		},
+		{
+			name:        "Cancelled context",
+			ctx:         cancelled,
+			expectedErr: context.Canceled,
+		},
+		{
+			name:              "Query timeout",
+			ctx:               context.Background(),
+			queryTimeout:      time.Nanosecond,
+			expectedErr:       context.DeadlineExceeded,
+			expectedTransient: true,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openSQLite(t, config.StorageConfig{OnConflict: storage.ConflictUpdate, AutoMigrate: true,
+				QueryTimeout: tt.queryTimeout})
+
+			_, insertErr := db.InsertOrder(tt.ctx, order)
+			_, readErr := db.GetAllOrders(tt.ctx)
+
+			// Assert expectations
+			for _, err := range []error{insertErr, readErr} {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, tt.expectedTransient, storage.IsTransient(err))
+			}
+		})
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+
+	ctx := context.Background()
+	db := newSQLite(t, storage.ConflictUpdate)
+
+	mg, err := db.NewMigrator(ctx)
+	require.NoError(t, err)
+	defer mg.Close()
+
+	// Assert expectations
+	version, dirty, err := mg.Version()
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), version)
+	assert.False(t, dirty)
+
+	require.NoError(t, mg.Down(2))
+	_, err = db.GetOrderByUID(ctx, "missing")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, storage.ErrOrderNotFound)
+
+	require.NoError(t, mg.Up())
+	_, err = db.GetOrderByUID(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
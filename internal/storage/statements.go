@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// queries of the insert path, run for every consumed order
+const (
+	insertOrderQuery = `
+		INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (order_uid) DO NOTHING
+	`
+	insertDeliveryQuery = `
+		INSERT INTO delivery (
+			order_uid, name, phone, zip, city, address, region, email
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	insertPaymentQuery = `
+		INSERT INTO payment (
+			order_uid, "transaction", request_id, currency, provider, amount,
+			payment_dt, bank, delivery_cost, goods_total, custom_fee
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	insertItemQuery = `
+		INSERT INTO items (
+			order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+)
+
+// statements of the insert path prepared once and shared by all transactions
+type insertStatements struct {
+	order    *sql.Stmt
+	delivery *sql.Stmt
+	payment  *sql.Stmt
+	item     *sql.Stmt
+}
+
+// statements prepares the insert path on first use. Tables may be missing until
+// migrations are applied, so a failed preparation is tried again by the next call.
+func (db *SQLDB) statements(ctx context.Context) (*insertStatements, error) {
+	db.stmtMu.Lock()
+	defer db.stmtMu.Unlock()
+	if db.stmts != nil {
+		return db.stmts, nil
+	}
+
+	stmts := &insertStatements{}
+	for _, s := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&stmts.order, insertOrderQuery},
+		{&stmts.delivery, insertDeliveryQuery},
+		{&stmts.payment, insertPaymentQuery},
+		{&stmts.item, insertItemQuery},
+	} {
+		stmt, err := db.Conn.PrepareContext(ctx, s.query)
+		if err != nil {
+			stmts.close()
+			return nil, fmt.Errorf("failed to prepare insert statement: %w", err)
+		}
+		*s.stmt = stmt
+	}
+	db.stmts = stmts
+	return stmts, nil
+}
+
+func (s *insertStatements) close() error {
+	var errs []error
+	for _, stmt := range []*sql.Stmt{s.order, s.delivery, s.payment, s.item} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -3,300 +3,263 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"iter"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/EgorcaA/create_db/internal/config"
+	"github.com/EgorcaA/create_db/internal/metrics"
 	"github.com/EgorcaA/create_db/internal/order_struct"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Check if a database exists
-func databaseExists(db *sql.DB, dbName string) (bool, error) {
-	query := "SELECT 1 FROM pg_database WHERE datname = $1"
-	var exists int
-	err := db.QueryRow(query, dbName).Scan(&exists)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
-}
+// SQLDB is Database stored in PostgreSQL or SQLite, see NewPostgres, NewSQLite and Open
+type SQLDB struct {
+	Conn *sql.DB
 
-func checkTableExists(db *sql.DB, tableName string) (bool, error) {
-	query := `
-		SELECT EXISTS (
-			SELECT 1 
-			FROM information_schema.tables 
-			WHERE table_schema = 'public' AND table_name = $1
-		)
-	`
+	dialect dialect
+	// what InsertOrder does with a changed payload of a stored order
+	onConflict string
+	// deadline of a single query or transaction, 0 means none
+	queryTimeout time.Duration
 
-	var exists bool
-	err := db.QueryRow(query, tableName).Scan(&exists)
-	if err != nil {
-		return false, err
-	}
-	return exists, nil
+	// insert path statements, prepared on first use
+	stmtMu sync.Mutex
+	stmts  *insertStatements
 }
 
-type PostgresDB struct {
-	Conn *sql.DB
+// dialect holds what differs between the SQL backends
+type dialect struct {
+	// db.system attribute of spans and the migrations directory
+	name string
+	// dsn the migrator connects with, when it needs its own connection
+	dsn string
+	// locks the order row selected in a transaction until its end
+	forUpdate string
+	// converts date_created to a query argument
+	timeArg func(time.Time) any
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.49.1 --name=Database --outpkg=mocks --dir=.
 type Database interface {
-	InsertOrder(ctx context.Context, order order_struct.Order) error
-	GetAllOrders() ([]order_struct.Order, error)
+	InsertOrder(ctx context.Context, order order_struct.Order) (InsertResult, error)
+	GetAllOrders(ctx context.Context) ([]order_struct.Order, error)
+	GetOrderByUID(ctx context.Context, orderUID string) (order_struct.Order, error)
+	GetOrdersByCustomer(ctx context.Context, customerID string) ([]order_struct.Order, error)
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) (order_struct.Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error)
+	RecentOrders(ctx context.Context, since time.Time, batchSize int) iter.Seq2[order_struct.Order, error]
 }
 
-// returns new postres db connection
-func New(log *slog.Logger, Postgres_conf config.PostgresConfig) (*PostgresDB, error) {
-
-	serverConnStr := fmt.Sprintf("host=%s port=%d user=%s password=%s sslmode=disable dbname=postgres",
-		Postgres_conf.Host,
-		Postgres_conf.Port,
-		Postgres_conf.User,
-		Postgres_conf.Password,
-	)
-	serverDb, err := sql.Open("postgres", serverConnStr)
-	if err != nil {
-		log.Error(fmt.Sprintf("Failed to connect to PostgreSQL server: %v", err))
+// Open connects the storage backend selected by app config,
+// postgres config is used only by the postgres backend
+func Open(log *slog.Logger, app_conf config.AppConfig, storage_conf config.StorageConfig,
+	Postgres_conf config.PostgresConfig) (*SQLDB, error) {
+	switch app_conf.Storage {
+	case StoragePostgres:
+		return NewPostgres(log, Postgres_conf, storage_conf)
+	case StorageSQLite:
+		return NewSQLite(log, app_conf.StoragePath, storage_conf)
 	}
-	log.Info("PostgreSQL server opened successfully!")
+	return nil, fmt.Errorf("unknown storage %q, use %q or %q", app_conf.Storage, StoragePostgres, StorageSQLite)
+}
 
-	// Check if the database exists and if not create it
-	exists, err := databaseExists(serverDb, Postgres_conf.Name)
-	if err != nil {
-		log.Warn(fmt.Sprintf("Failed to check database existence: %v", err))
-		query := fmt.Sprintf("CREATE DATABASE %s;", Postgres_conf.Name)
-		_, err = serverDb.Exec(query)
-		if err != nil {
-			log.Warn(fmt.Sprintf("Error creating database %s: %v (it might already exist)", Postgres_conf.Name, err))
-		} else {
-			log.Info(fmt.Sprintf("Database %s created successfully!", Postgres_conf.Name))
-		}
+// storage backends
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
 
+// Ping checks the db is reachable
+func (db *SQLDB) Ping(ctx context.Context) error {
+	return db.Conn.PingContext(ctx)
+}
+
+// Close releases prepared statements and closes the connection pool
+func (db *SQLDB) Close() error {
+	db.stmtMu.Lock()
+	defer db.stmtMu.Unlock()
+	var err error
+	if db.stmts != nil {
+		err = db.stmts.close()
+		db.stmts = nil
 	}
-	// connecting db
-	serverConnStr = fmt.Sprintf("host=%s port=%d user=%s password=%s sslmode=disable dbname=%s",
-		Postgres_conf.Host,
-		Postgres_conf.Port,
-		Postgres_conf.User,
-		Postgres_conf.Password,
-		Postgres_conf.Name)
-	serverDb, err = sql.Open("postgres", serverConnStr)
-	if err != nil {
-		log.Error(fmt.Sprintf("Failed to connect to PostgreSQL db %s: %v", Postgres_conf.Name, err))
-	} else {
-		log.Info(fmt.Sprintf("Successfully connected to PostgreSQL db %s", Postgres_conf.Name))
-	}
+	return errors.Join(err, db.Conn.Close())
+}
 
-	// Create tables if they do not exist
-	exists, err = checkTableExists(serverDb, "orders")
-	if !exists {
-		query := `
-		CREATE TABLE orders (
-			order_uid VARCHAR PRIMARY KEY,
-			track_number VARCHAR NOT NULL,
-			entry VARCHAR NOT NULL,
-			locale VARCHAR NOT NULL,
-			internal_signature VARCHAR,
-			customer_id VARCHAR NOT NULL,
-			delivery_service VARCHAR NOT NULL,
-			shardkey VARCHAR NOT NULL,
-			sm_id INT NOT NULL,
-			date_created TIMESTAMP NOT NULL,
-			oof_shard VARCHAR NOT NULL
-		);
-		
-		-- Таблица доставки
-		CREATE TABLE delivery (
-			order_uid VARCHAR PRIMARY KEY REFERENCES orders(order_uid),
-			name VARCHAR NOT NULL,
-			phone VARCHAR NOT NULL,
-			zip VARCHAR NOT NULL,
-			city VARCHAR NOT NULL,
-			address VARCHAR NOT NULL,
-			region VARCHAR NOT NULL,
-			email VARCHAR NOT NULL
-		);
-		
-		-- Таблица оплаты
-		CREATE TABLE payment (
-			transaction VARCHAR PRIMARY KEY,
-			order_uid VARCHAR NOT NULL REFERENCES orders(order_uid),
-			request_id VARCHAR,
-			currency VARCHAR NOT NULL,
-			provider VARCHAR NOT NULL,
-			amount INT NOT NULL,
-			payment_dt BIGINT NOT NULL,
-			bank VARCHAR NOT NULL,
-			delivery_cost INT NOT NULL,
-			goods_total INT NOT NULL,
-			custom_fee INT NOT NULL
-		);
-		
-		-- Таблица товаров в заказе
-		CREATE TABLE items (
-			id SERIAL PRIMARY KEY,
-			order_uid VARCHAR NOT NULL REFERENCES orders(order_uid),
-			chrt_id BIGINT NOT NULL,
-			track_number VARCHAR NOT NULL,
-			price INT NOT NULL,
-			rid VARCHAR NOT NULL,
-			name VARCHAR NOT NULL,
-			sale INT NOT NULL,
-			size VARCHAR NOT NULL,
-			total_price INT NOT NULL,
-			nm_id BIGINT NOT NULL,
-			brand VARCHAR NOT NULL,
-			status INT NOT NULL
-		);`
-		_, err = serverDb.Exec(query)
-		if err != nil {
-			log.Error(fmt.Sprintf("Failed to create tables: %v", err))
-		} else {
-			log.Info("Created tables")
-		}
+// withTimeout bounds a single query or transaction by the configured deadline,
+// a sooner deadline of ctx is kept
+func (db *SQLDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
 
-	return &PostgresDB{Conn: serverDb}, nil
+// InsertOrder stores the order in a single transaction and reports whether it was
+// created, already stored with the same payload or updated.
+// Returned error wraps ErrDuplicateOrder when the stored order differs and the
+// conflict policy keeps it, or ErrInvalidOrder when retrying makes no sense.
+func (db *SQLDB) InsertOrder(ctx context.Context, order order_struct.Order) (InsertResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "InsertOrder",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", db.dialect.name), attribute.String("order.uid", order.OrderUID)))
+	defer span.End()
 
+	start := time.Now()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	result, err := db.insertOrder(ctx, order)
+	err = classifyError(contextError(ctx, err))
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.String("result", result.String()))
+	switch {
+	case errors.Is(err, ErrDuplicateOrder):
+		metrics.InsertOrder(start, "duplicate")
+	case err != nil:
+		metrics.InsertOrder(start, "error")
+	default:
+		metrics.InsertOrder(start, result.String())
+	}
+	return result, err
 }
 
-func (db *PostgresDB) InsertOrder(ctx context.Context, order order_struct.Order) error {
-	tx, err := db.Conn.Begin()
+func (db *SQLDB) insertOrder(ctx context.Context, order order_struct.Order) (InsertResult, error) {
+	stmts, err := db.statements(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	// Insert into orders table
-	_, err = tx.Exec(`
-		INSERT INTO orders (
-			order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard)
+	// Insert into orders table, existing row is left for comparison
+	res, err := tx.StmtContext(ctx, stmts.order).ExecContext(ctx,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, db.dialect.timeArg(order.DateCreated), order.OOFShard)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := res.RowsAffected()
 	if err != nil {
-		tx.Rollback()
-		return err
+		return 0, err
+	}
+
+	result := InsertCreated
+	if inserted == 0 {
+		// order is already stored, lock it and compare payloads
+		stored, err := selectOrder(ctx, tx, order.OrderUID, db.dialect.forUpdate)
+		if err != nil {
+			return 0, err
+		}
+		if sameOrder(stored, order) {
+			return InsertUnchanged, nil
+		}
+		if db.onConflict != ConflictUpdate {
+			return 0, fmt.Errorf("%w: stored order %s differs", ErrDuplicateOrder, order.OrderUID)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders SET
+				track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+				customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9,
+				date_created = $10, oof_shard = $11
+			WHERE order_uid = $1
+		`, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+			order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, db.dialect.timeArg(order.DateCreated), order.OOFShard)
+		if err != nil {
+			return 0, err
+		}
+		// children are replaced as a whole
+		for _, table := range []string{"delivery", "payment", "items"} {
+			if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE order_uid = $1", order.OrderUID); err != nil {
+				return 0, err
+			}
+		}
+		result = InsertUpdated
 	}
 
 	// Insert into deliveries table
-	_, err = tx.Exec(`
-		INSERT INTO delivery (
-			order_uid, name, phone, zip, city, address, region, email
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
+	_, err = tx.StmtContext(ctx, stmts.delivery).ExecContext(ctx,
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
 		order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	// Insert into payments table
-	_, err = tx.Exec(`
-		INSERT INTO payment (
-			order_uid, transaction, request_id, currency, provider, amount,
-			payment_dt, bank, delivery_cost, goods_total, custom_fee
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+	_, err = tx.StmtContext(ctx, stmts.payment).ExecContext(ctx,
+		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
 		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
 		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	// Insert into items table
+	itemStmt := tx.StmtContext(ctx, stmts.item)
 	for _, item := range order.Items {
-		_, err := tx.Exec(`
-			INSERT INTO items (
-				order_uid, chrt_id, track_number, price, rid, name, sale, size,
-				total_price, nm_id, brand, status
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		`, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
+		_, err := itemStmt.ExecContext(ctx,
+			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
 			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
 		if err != nil {
-			tx.Rollback()
-			return err
+			return 0, err
 		}
 	}
 
-	return tx.Commit()
+	return result, tx.Commit()
 }
 
 // GetAllOrders retrieves all orders along with their associated delivery, payment, and items.
-func (db *PostgresDB) GetAllOrders() ([]order_struct.Order, error) {
-	// SQL query to join the orders table with delivery, payment, and items
-	query := `
-		SELECT 
-			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
-			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, 
-			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
-			i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, 
-			i.nm_id, i.brand, i.status
-		FROM orders o
-		LEFT JOIN delivery d ON o.order_uid = d.order_uid
-		LEFT JOIN payment p ON o.order_uid = p.order_uid
-		LEFT JOIN items i ON o.order_uid = i.order_uid
-	`
-
-	// Execute the query
-	rows, err := db.Conn.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query orders: %w", err)
-	}
-	defer rows.Close()
-
-	// Slice to hold all orders
+// Prefer AllOrders for big tables, it doesn't hold every order in memory.
+func (db *SQLDB) GetAllOrders(ctx context.Context) ([]order_struct.Order, error) {
 	var orders []order_struct.Order
-
-	// Iterate over the rows and build the orders slice
-	for rows.Next() {
-		var order order_struct.Order
-		var item order_struct.Item
-		var delivery order_struct.Delivery
-		var payment order_struct.Payment
-
-		// Scan the row into the corresponding fields
-		err := rows.Scan(
-			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-			&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
-			&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
-			&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDT,
-			&payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
-			&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale, &item.Size, &item.TotalPrice,
-			&item.NmID, &item.Brand, &item.Status,
-		)
+	for order, err := range db.AllOrders(ctx, DefaultListLimit) {
 		if err != nil {
-			log.Println("Error scanning row:", err)
-			continue
+			return nil, err
 		}
-
-		// Add delivery and payment data to the order
-		order.Delivery = delivery
-		order.Payment = payment
-
-		// Add the item to the order's items slice
-		order.Items = append(order.Items, item)
-
-		// Append the order to the orders slice
 		orders = append(orders, order)
 	}
+	return orders, nil
+}
 
-	// Check for any errors during iteration
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
+// AllOrders iterates over all orders from newest to oldest reading them in batches.
+// Iteration stops after the first error.
+func (db *SQLDB) AllOrders(ctx context.Context, batchSize int) iter.Seq2[order_struct.Order, error] {
+	return db.Orders(ctx, OrderFilter{Limit: batchSize})
+}
 
-	return orders, nil
+// RecentOrders iterates over orders created since the given time, all of them for zero time
+func (db *SQLDB) RecentOrders(ctx context.Context, since time.Time, batchSize int) iter.Seq2[order_struct.Order, error] {
+	return db.Orders(ctx, OrderFilter{From: since, Limit: batchSize})
+}
+
+// Orders iterates over orders matching the filter from newest to oldest,
+// filter.Limit is the batch size. Iteration stops after the first error.
+func (db *SQLDB) Orders(ctx context.Context, filter OrderFilter) iter.Seq2[order_struct.Order, error] {
+	return func(yield func(order_struct.Order, error) bool) {
+		for {
+			page, err := db.ListOrders(ctx, filter)
+			if err != nil {
+				yield(order_struct.Order{}, err)
+				return
+			}
+			for _, order := range page.Orders {
+				if !yield(order, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			filter.Cursor = page.NextCursor
+		}
+	}
 }
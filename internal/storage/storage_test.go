@@ -0,0 +1,281 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// order as it is read back: wall clock with microsecond precision in UTC
+func fakeOrder(created time.Time) order_struct.Order {
+	order := generator.GenerateFakeOrder()
+	order.DateCreated = created
+	return order
+}
+
+// testInsertOrder checks upsert semantics of a storage backend
+func testInsertOrder(t *testing.T, open func(t *testing.T, onConflict string) *storage.SQLDB) {
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	order := fakeOrder(created)
+	changed := order
+	changed.Items = order.Items[:1]
+	changed.Delivery.City = "Changed"
+	colliding := fakeOrder(created)
+	colliding.Payment.Transaction = order.Payment.Transaction
+
+	ctx := context.Background()
+
+	// Define test cases
+	tests := []struct {
+		name           string
+		onConflict     string
+		stored         []order_struct.Order
+		insert         order_struct.Order
+		expectedResult storage.InsertResult
+		expectedErr    error
+		expectedOrder  order_struct.Order
+	}{
+		{
+			name:           "New order",
+			onConflict:     storage.ConflictUpdate,
+			insert:         order,
+			expectedResult: storage.InsertCreated,
+			expectedOrder:  order,
+		},
+		{
+			name:           "Redelivered order",
+			onConflict:     storage.ConflictUpdate,
+			stored:         []order_struct.Order{order},
+			insert:         order,
+			expectedResult: storage.InsertUnchanged,
+			expectedOrder:  order,
+		},
+		{
+			name:           "Changed order is updated",
+			onConflict:     storage.ConflictUpdate,
+			stored:         []order_struct.Order{order},
+			insert:         changed,
+			expectedResult: storage.InsertUpdated,
+			expectedOrder:  changed,
+		},
+		{
+			name:          "Changed order is kept",
+			onConflict:    storage.ConflictKeep,
+			stored:        []order_struct.Order{order},
+			insert:        changed,
+			expectedErr:   storage.ErrDuplicateOrder,
+			expectedOrder: order,
+		},
+		{
+			name:        "New order with a stored transaction",
+			onConflict:  storage.ConflictUpdate,
+			stored:      []order_struct.Order{order},
+			insert:      colliding,
+			expectedErr: storage.ErrInvalidOrder,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := open(t, tt.onConflict)
+			for _, stored := range tt.stored {
+				_, err := db.InsertOrder(ctx, stored)
+				require.NoError(t, err)
+			}
+
+			result, err := db.InsertOrder(ctx, tt.insert)
+
+			// Assert expectations
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.expectedResult, result)
+			}
+			stored, err := db.GetOrderByUID(ctx, tt.insert.OrderUID)
+			if tt.expectedOrder.OrderUID == "" {
+				assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+				return
+			}
+			require.NoError(t, err)
+			// postgres reads timestamps back in a zero offset zone, not UTC
+			stored.DateCreated = stored.DateCreated.UTC()
+			assert.Equal(t, tt.expectedOrder, stored)
+		})
+	}
+}
+
+// testListOrders checks filters of a storage backend listing orders
+func testListOrders(t *testing.T, open func(t *testing.T, onConflict string) *storage.SQLDB) {
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	orders := make([]order_struct.Order, 5)
+	for i := range orders {
+		// newest first, as orders are listed
+		orders[i] = fakeOrder(start.Add(time.Duration(len(orders)-i) * time.Hour))
+	}
+	orders[1].CustomerID = "customer"
+	orders[3].CustomerID = "customer"
+
+	ctx := context.Background()
+	db := open(t, storage.ConflictUpdate)
+	for _, order := range orders {
+		_, err := db.InsertOrder(ctx, order)
+		require.NoError(t, err)
+	}
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		filter   storage.OrderFilter
+		expected []order_struct.Order
+	}{
+		{
+			name:     "All orders in pages",
+			filter:   storage.OrderFilter{Limit: 2},
+			expected: orders,
+		},
+		{
+			name:     "Customer",
+			filter:   storage.OrderFilter{CustomerID: "customer", Limit: 1},
+			expected: []order_struct.Order{orders[1], orders[3]},
+		},
+		{
+			name:     "Date range",
+			filter:   storage.OrderFilter{From: orders[3].DateCreated, To: orders[0].DateCreated},
+			expected: orders[1:4],
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listed []order_struct.Order
+			for order, err := range db.Orders(ctx, tt.filter) {
+				require.NoError(t, err)
+				order.DateCreated = order.DateCreated.UTC()
+				listed = append(listed, order)
+			}
+
+			// Assert expectations
+			assert.Equal(t, tt.expected, listed)
+		})
+	}
+}
+
+// testListOrderPages checks limits, cursors and filters of a storage backend paging orders
+func testListOrderPages(t *testing.T, open func(t *testing.T, onConflict string) *storage.SQLDB) {
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	// one more than fits a page
+	orders := make([]order_struct.Order, storage.MaxListLimit+1)
+	for i := range orders {
+		// newest first, as orders are listed
+		orders[i] = fakeOrder(start.Add(time.Duration(len(orders)-i) * time.Minute))
+	}
+	orders[0].Locale = "ru"
+	orders[2].Locale = "ru"
+	orders[1].DeliveryService = "courier"
+	orders[3].Payment.Provider = "card"
+
+	ctx := context.Background()
+	db := open(t, storage.ConflictUpdate)
+	for _, order := range orders {
+		_, err := db.InsertOrder(ctx, order)
+		require.NoError(t, err)
+	}
+
+	first, err := db.ListOrders(ctx, storage.OrderFilter{Limit: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, first.NextCursor)
+	cursor := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	// Define test cases
+	tests := []struct {
+		name         string
+		filter       storage.OrderFilter
+		expected     []order_struct.Order
+		expectedNext bool
+		expectedErr  error
+	}{
+		{
+			name:         "Default limit",
+			filter:       storage.OrderFilter{},
+			expected:     orders[:storage.DefaultListLimit],
+			expectedNext: true,
+		},
+		{
+			name:         "Limit above max",
+			filter:       storage.OrderFilter{Limit: 2 * storage.MaxListLimit},
+			expected:     orders[:storage.MaxListLimit],
+			expectedNext: true,
+		},
+		{
+			name:         "Next page",
+			filter:       storage.OrderFilter{Cursor: first.NextCursor, Limit: 2},
+			expected:     orders[2:4],
+			expectedNext: true,
+		},
+		{
+			name:     "Last page",
+			filter:   storage.OrderFilter{Cursor: first.NextCursor, Locale: "ru", Limit: 1},
+			expected: []order_struct.Order{orders[2]},
+		},
+		{
+			name:     "Locale",
+			filter:   storage.OrderFilter{Locale: "ru"},
+			expected: []order_struct.Order{orders[0], orders[2]},
+		},
+		{
+			name:     "Delivery service",
+			filter:   storage.OrderFilter{DeliveryService: "courier"},
+			expected: []order_struct.Order{orders[1]},
+		},
+		{
+			name:     "Payment provider",
+			filter:   storage.OrderFilter{PaymentProvider: "card"},
+			expected: []order_struct.Order{orders[3]},
+		},
+		{
+			name:        "Cursor is not base64",
+			filter:      storage.OrderFilter{Cursor: "not a cursor"},
+			expectedErr: storage.ErrInvalidCursor,
+		},
+		{
+			name:        "Cursor without separator",
+			filter:      storage.OrderFilter{Cursor: cursor("2024-05-01T00:00:00Z")},
+			expectedErr: storage.ErrInvalidCursor,
+		},
+		{
+			name:        "Cursor with malformed date",
+			filter:      storage.OrderFilter{Cursor: cursor("yesterday|" + orders[0].OrderUID)},
+			expectedErr: storage.ErrInvalidCursor,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := db.ListOrders(ctx, tt.filter)
+
+			// Assert expectations
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			for i := range page.Orders {
+				page.Orders[i].DateCreated = page.Orders[i].DateCreated.UTC()
+			}
+			assert.Equal(t, tt.expected, page.Orders)
+			assert.Equal(t, tt.expectedNext, page.NextCursor != "")
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+)
+
+// policies for a redelivered order whose payload differs from the stored one
+const (
+	// stored order is replaced by the new payload
+	ConflictUpdate = "update"
+	// stored order is kept, InsertOrder returns ErrDuplicateOrder
+	ConflictKeep = "keep"
+)
+
+// InsertResult tells what InsertOrder did with the order
+type InsertResult int
+
+const (
+	// order was not stored before
+	InsertCreated InsertResult = iota
+	// order was stored with the same payload, nothing changed
+	InsertUnchanged
+	// order was stored with a different payload and is updated
+	InsertUpdated
+)
+
+func (r InsertResult) String() string {
+	switch r {
+	case InsertCreated:
+		return "created"
+	case InsertUnchanged:
+		return "unchanged"
+	case InsertUpdated:
+		return "updated"
+	}
+	return fmt.Sprintf("InsertResult(%d)", int(r))
+}
+
+// sameOrder compares orders the way they are stored in db
+func sameOrder(a, b order_struct.Order) bool {
+	a.DateCreated, b.DateCreated = storedTime(a.DateCreated), storedTime(b.DateCreated)
+	if len(a.Items) == 0 {
+		a.Items = nil
+	}
+	if len(b.Items) == 0 {
+		b.Items = nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// TIMESTAMP column keeps wall clock with microsecond precision and drops the zone
+func storedTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond()/1000*1000, time.UTC)
+}
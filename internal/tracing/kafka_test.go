@@ -0,0 +1,72 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/tracing"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKafkaPropagation(t *testing.T) {
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	// Define test cases
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		headers       []sarama.RecordHeader
+		expectedValid bool
+	}{
+		{
+			name:          "Trace context is carried",
+			ctx:           ctx,
+			expectedValid: true,
+		},
+		{
+			name:          "Other headers are kept",
+			ctx:           ctx,
+			headers:       []sarama.RecordHeader{{Key: []byte("x-original-topic"), Value: []byte("orders")}},
+			expectedValid: true,
+		},
+		{
+			name:          "No trace",
+			ctx:           context.Background(),
+			expectedValid: false,
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			produced := &sarama.ProducerMessage{Topic: "orders", Headers: tt.headers}
+			tracing.Inject(tt.ctx, produced)
+
+			// headers arrive to the consumer as pointers
+			consumed := &sarama.ConsumerMessage{Topic: "orders"}
+			for i := range produced.Headers {
+				consumed.Headers = append(consumed.Headers, &produced.Headers[i])
+			}
+			got := trace.SpanContextFromContext(tracing.Extract(context.Background(), consumed))
+
+			// Assert expectations
+			assert.Equal(t, tt.expectedValid, got.IsValid())
+			if tt.expectedValid {
+				assert.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+				assert.True(t, got.IsRemote())
+			}
+			assert.GreaterOrEqual(t, len(produced.Headers), len(tt.headers))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EgorcaA/create_db/internal/order_struct"
+)
+
+// what the consumer does with an order that has violations
+const (
+	// order is not stored and goes to the dead-letter topic
+	ModeReject = "reject"
+	// violations are logged, order is stored anyway
+	ModeWarn = "warn"
+	// order is not stored and goes to the quarantine topic for review
+	ModeQuarantine = "quarantine"
+)
+
+// Violation describes a single invalid field
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+// Violations is the list of problems of an order, it is used as an error
+type Violations []Violation
+
+func (vs Violations) Error() string {
+	parts := make([]string, 0, len(vs))
+	for _, v := range vs {
+		parts = append(parts, v.String())
+	}
+	return "invalid order: " + strings.Join(parts, "; ")
+}
+
+func (vs *Violations) add(field, format string, args ...any) {
+	*vs = append(*vs, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks order fields and totals consistency, nil means the order is valid
+func Validate(order order_struct.Order) Violations {
+	var vs Violations
+
+	// order
+	if order.OrderUID == "" {
+		vs.add("order_uid", "is empty")
+	}
+	if order.TrackNumber == "" {
+		vs.add("track_number", "is empty")
+	}
+	if order.CustomerID == "" {
+		vs.add("customer_id", "is empty")
+	}
+	if order.DateCreated.IsZero() {
+		vs.add("date_created", "is not set")
+	}
+
+	// payment
+	p := order.Payment
+	if p.Transaction == "" {
+		vs.add("payment.transaction", "is empty")
+	}
+	if p.Currency == "" {
+		vs.add("payment.currency", "is empty")
+	}
+	for _, f := range []struct {
+		field string
+		value int
+	}{
+		{"payment.amount", p.Amount},
+		{"payment.delivery_cost", p.DeliveryCost},
+		{"payment.goods_total", p.GoodsTotal},
+		{"payment.custom_fee", p.CustomFee},
+	} {
+		if f.value < 0 {
+			vs.add(f.field, "is negative: %d", f.value)
+		}
+	}
+
+	// items
+	if len(order.Items) == 0 {
+		vs.add("items", "is empty")
+	}
+	itemsTotal := 0
+	for i, item := range order.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.Price < 0 {
+			vs.add(field+".price", "is negative: %d", item.Price)
+		}
+		if item.Sale < 0 || item.Sale > 100 {
+			vs.add(field+".sale", "is out of 0..100: %d", item.Sale)
+		} else if expected := ItemTotal(item.Price, item.Sale); item.TotalPrice != expected {
+			vs.add(field+".total_price", "is %d, expected %d for price %d and sale %d%%",
+				item.TotalPrice, expected, item.Price, item.Sale)
+		}
+		itemsTotal += item.TotalPrice
+	}
+
+	// totals
+	if len(order.Items) > 0 && p.GoodsTotal != itemsTotal {
+		vs.add("payment.goods_total", "is %d, items sum is %d", p.GoodsTotal, itemsTotal)
+	}
+	if expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != expected {
+		vs.add("payment.amount", "is %d, goods_total + delivery_cost + custom_fee is %d", p.Amount, expected)
+	}
+
+	return vs
+}
+
+// ItemTotal returns item price after the sale (percent), rounded down
+func ItemTotal(price, sale int) int {
+	return price * (100 - sale) / 100
+}
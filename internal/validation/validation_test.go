@@ -0,0 +1,72 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/EgorcaA/create_db/internal/generator"
+	"github.com/EgorcaA/create_db/internal/order_struct"
+	"github.com/EgorcaA/create_db/internal/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+
+	// Define test cases
+	tests := []struct {
+		name   string
+		modify func(order *order_struct.Order)
+		fields []string
+	}{
+		{
+			name:   "Valid order",
+			modify: func(order *order_struct.Order) {},
+			fields: nil,
+		},
+		{
+			name:   "Empty OrderUID",
+			modify: func(order *order_struct.Order) { order.OrderUID = "" },
+			fields: []string{"order_uid"},
+		},
+		{
+			name: "Negative delivery cost",
+			modify: func(order *order_struct.Order) {
+				order.Payment.DeliveryCost = -1
+				order.Payment.Amount = order.Payment.GoodsTotal - 1
+			},
+			fields: []string{"payment.delivery_cost"},
+		},
+		{
+			name: "Item total doesn't match price and sale",
+			modify: func(order *order_struct.Order) {
+				order.Items[0].TotalPrice++
+				order.Payment.GoodsTotal++
+				order.Payment.Amount++
+			},
+			fields: []string{"items[0].total_price"},
+		},
+		{
+			name:   "Goods total disagrees with items",
+			modify: func(order *order_struct.Order) { order.Payment.GoodsTotal++ },
+			fields: []string{"payment.goods_total", "payment.amount"},
+		},
+		{
+			name:   "No items",
+			modify: func(order *order_struct.Order) { order.Items = nil },
+			fields: []string{"items"},
+		},
+	}
+
+	// Run test cases
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := generator.GenerateFakeOrder()
+			tt.modify(&order)
+
+			var fields []string
+			for _, v := range validation.Validate(order) {
+				fields = append(fields, v.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}